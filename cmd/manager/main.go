@@ -11,12 +11,13 @@ import (
 	"github.com/operator-framework/operator-sdk/pkg/log/zap"
 	sdkVersion "github.com/operator-framework/operator-sdk/version"
 	"github.com/spf13/pflag"
-	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
 	halkyon "halkyon.io/api"
 	"halkyon.io/operator-framework"
 	capability2 "halkyon.io/operator-framework/plugins/capability"
 	"halkyon.io/operator/pkg/controller/capability"
 	"halkyon.io/operator/pkg/controller/component"
+	"halkyon.io/operator/pkg/tekton"
+	"halkyon.io/operator/pkg/webhook"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -180,6 +181,18 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Serve the push webhooks and the source archive uploads triggering component builds
+	if err := mgr.Add(webhook.NewServer(mgr.GetClient())); err != nil {
+		log.Error(err, "")
+		os.Exit(1)
+	}
+
+	// Poll the git repositories of the components enabling it
+	if err := mgr.Add(component.NewPoller(mgr.GetClient())); err != nil {
+		log.Error(err, "")
+		os.Exit(1)
+	}
+
 	// Start the Cmd
 	if err := mgr.Start(signals.SetupSignalHandler()); err != nil {
 		log.Error(err, "Manager exited non-zero")
@@ -198,8 +211,13 @@ func registerAdditionalResources(m manager.Manager) {
 	if err := route.Install(scheme); err != nil {
 		log.Error(err, "")
 	}
-	if err := tektonv1.AddToScheme(scheme); err != nil {
+	// use the most recent version of the Tekton API installed on the cluster
+	if version, err := tekton.DetectVersion(m.GetConfig()); err != nil {
+		log.Error(err, "builds won't be available")
+	} else if err := tekton.Register(scheme, version); err != nil {
 		log.Error(err, "")
+	} else {
+		log.Info("using Tekton " + tekton.SchemeGroupVersion.String() + " API")
 	}
 	if err := image.Install(scheme); err != nil {
 		log.Error(err, "")
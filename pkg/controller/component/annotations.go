@@ -0,0 +1,195 @@
+package component
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	component "halkyon.io/api/component/v1beta1"
+	"strings"
+)
+
+// The Component API doesn't (yet) expose every aspect of the resources the operator generates. Until it does, such
+// settings are read from annotations on the Component, complex values being provided as JSON-encoded Kubernetes types.
+const (
+	annotationPrefix = "component.halkyon.io/"
+	// EnvAnnotation holds a JSON list of EnvVar, allowing values to be sourced from Secrets or ConfigMaps using valueFrom
+	EnvAnnotation = annotationPrefix + "env"
+	// EnvFromAnnotation holds a JSON list of EnvFromSource, exposing whole Secrets or ConfigMaps as environment variables
+	EnvFromAnnotation = annotationPrefix + "env-from"
+	// VolumesAnnotation holds a JSON list of additional Volume to add to the component's pod
+	VolumesAnnotation = annotationPrefix + "volumes"
+	// VolumeMountsAnnotation holds a JSON list of VolumeMount for the additional volumes, applied to the runtime container
+	VolumeMountsAnnotation = annotationPrefix + "volume-mounts"
+	// ContainersAnnotation holds a JSON list of sidecar Container to add to the component's pod
+	ContainersAnnotation = annotationPrefix + "containers"
+	// InitContainersAnnotation holds a JSON list of Container to run as init containers of the component's pod
+	InitContainersAnnotation = annotationPrefix + "init-containers"
+	// SchedulingAnnotation holds a JSON Scheduling object constraining where the component's pods can be scheduled
+	SchedulingAnnotation = annotationPrefix + "scheduling"
+	// BuildSchedulingAnnotation holds a JSON Scheduling object constraining where the build pods can be scheduled
+	BuildSchedulingAnnotation = annotationPrefix + "build-scheduling"
+	// PodSecurityAnnotation selects the security context of the component's pods: restricted (default), which requires
+	// images running as a non-root user and is therefore not supported in dev mode, or unrestricted (default in dev mode)
+	PodSecurityAnnotation = annotationPrefix + "pod-security"
+	// UnprivilegedBuildAnnotation, when set to "true", runs the build steps rootless instead of as privileged containers,
+	// complying with the baseline Pod Security level
+	UnprivilegedBuildAnnotation = annotationPrefix + "unprivileged-build"
+	// ImagePullSecretsAnnotation holds a comma-separated list of names of the Secrets to use to pull images. It can be set
+	// on Components as well as on Runtimes, for their image.
+	ImagePullSecretsAnnotation = annotationPrefix + "image-pull-secrets"
+	// PinImagesAnnotation, when set to "false", deploys images by tag instead of pinning them to their current digest
+	PinImagesAnnotation = annotationPrefix + "pin-images"
+	// ImagePullPolicyAnnotation overrides the pull policy of the component's images, IfNotPresent for pinned images and
+	// Always otherwise by default
+	ImagePullPolicyAnnotation = annotationPrefix + "image-pull-policy"
+	// ImageAnnotation holds the reference of the prebuilt image to deploy when using the image deployment mode
+	ImageAnnotation = annotationPrefix + "image"
+	// BuildAnnotation records on the generated pod template the name of the build which produced the deployed image
+	BuildAnnotation = annotationPrefix + "build"
+	// RebuildAnnotation triggers a new build of the component whenever its value changes, e.g. when bumped
+	RebuildAnnotation = annotationPrefix + "rebuild"
+	// BuildFingerprintAnnotation records on TaskRuns the fingerprint of what they build and on the component the fingerprint
+	// of its current build
+	BuildFingerprintAnnotation = annotationPrefix + "build-fingerprint"
+	// BuildNumberAnnotation records on TaskRuns their build number and on the component the number of its current build
+	BuildNumberAnnotation = annotationPrefix + "build-number"
+	// BuildAttemptAnnotation records on TaskRuns and on the component the attempt of the build, builds failing because of
+	// the infrastructure being retried
+	BuildAttemptAnnotation = annotationPrefix + "build-attempt"
+	// BuildTimeoutAnnotation sets the duration after which builds fail, e.g. 30m, 1h by default
+	BuildTimeoutAnnotation = annotationPrefix + "build-timeout"
+	// BuildRetriesAnnotation sets how many times builds failing because of the infrastructure are retried, 2 by default
+	BuildRetriesAnnotation = annotationPrefix + "build-retries"
+	// CancelBuildAnnotation holds the name of a build of the component to cancel if it's still running
+	CancelBuildAnnotation = annotationPrefix + "cancel-build"
+	// BuildFailureAnnotation records on failed TaskRuns a JSON BuildFailure describing the step which made them fail
+	BuildFailureAnnotation = annotationPrefix + "build-failure"
+	// BuildHistoryAnnotation records on the component a JSON list of BuildRecord describing its most recent builds
+	BuildHistoryAnnotation = annotationPrefix + "build-history"
+	// BuildHistoryLimitAnnotation sets how many builds of the component are kept, older TaskRuns being deleted
+	BuildHistoryLimitAnnotation = annotationPrefix + "build-history-limit"
+	// DeployedBuildAnnotation holds the name of a previous successful build whose image should be deployed instead of the
+	// one produced by the last successful build, e.g. to roll back
+	DeployedBuildAnnotation = annotationPrefix + "deployed-build"
+	// DeployedTagAnnotation holds the tag of the component's repository whose image should be deployed instead of the one
+	// produced by the last successful build, e.g. prod
+	DeployedTagAnnotation = annotationPrefix + "deployed-tag"
+	// PromoteAnnotation requests, as a JSON Promotion, to point a tag of the component's repository to the image another
+	// tag points to, e.g. {"from": "staging", "to": "prod"}, then to deploy it
+	PromoteAnnotation = annotationPrefix + "promote"
+	// ImageDigestAnnotation records on TaskRuns the digest of the image they produced
+	ImageDigestAnnotation = annotationPrefix + "image-digest"
+	// WebhookSecretAnnotation holds the name of the Secret containing the secret push webhooks triggering builds of the
+	// component must be signed with
+	WebhookSecretAnnotation = annotationPrefix + "webhook-secret"
+	// GitSecretAnnotation holds the name of the Secret holding the credentials used to access the component's git
+	// repository: a kubernetes.io/basic-auth Secret, e.g. with a token as password, for HTTP(S) repositories or a
+	// kubernetes.io/ssh-auth one for SSH repositories
+	GitSecretAnnotation = annotationPrefix + "git-secret"
+	// DockerfileAnnotation holds the path of the Dockerfile built by docker builds, relative to the build context path
+	DockerfileAnnotation = annotationPrefix + "dockerfile"
+	// BuildArgsAnnotation holds a JSON object of the build args passed to docker builds
+	BuildArgsAnnotation = annotationPrefix + "build-args"
+	// BuildpacksBuilderAnnotation holds the builder image used by buildpacks builds. It can be set on Components as well as
+	// on Runtimes, to provide a default for the components using them.
+	BuildpacksBuilderAnnotation = annotationPrefix + "buildpacks-builder"
+	// BuildCacheAnnotation selects where the dependencies and image layers downloaded by builds are cached between builds:
+	// none (default), component for a PVC owned by the component or namespace for a PVC shared by the namespace
+	BuildCacheAnnotation = annotationPrefix + "build-cache"
+	// BuildCacheSizeAnnotation sets the size of the build cache PVC when the operator creates it, 5Gi by default
+	BuildCacheSizeAnnotation = annotationPrefix + "build-cache-size"
+	// PurgeBuildCacheAnnotation purges the build cache, starting a new build with an empty cache, whenever its value
+	// changes, e.g. when bumped. It's also recorded on TaskRuns.
+	PurgeBuildCacheAnnotation = annotationPrefix + "purge-build-cache"
+	// S2IBuilderAnnotation holds, on Runtimes, the s2i builder image used to build the components using them unless they
+	// specify a base image in their buildConfig
+	S2IBuilderAnnotation = annotationPrefix + "s2i-builder"
+	// BuildEnvAnnotation holds a JSON object of the env vars passed to s2i builds, e.g. MAVEN_ARGS_APPEND. It can be set on
+	// Runtimes, to provide the default build env of their language, as well as on Components, to override it.
+	BuildEnvAnnotation = annotationPrefix + "build-env"
+	// BuildLogLevelAnnotation sets the log level of s2i builds, from 0 to 5 (default)
+	BuildLogLevelAnnotation = annotationPrefix + "build-log-level"
+	// SourceArchiveAnnotation builds the component from a source archive instead of its git repository, as specified by a
+	// JSON SourceArchive, e.g. {"persistentVolumeClaim": "uploads", "path": "backend.tar.gz"}. It's set by the operator
+	// when a source archive is uploaded for the component. It's also recorded on TaskRuns.
+	SourceArchiveAnnotation = annotationPrefix + "source-archive"
+	// PollIntervalAnnotation enables polling the component's git repository at the specified interval, e.g. 5m, a new
+	// build being triggered whenever the commit its ref points to changes
+	PollIntervalAnnotation = annotationPrefix + "poll-interval"
+	// PolledCommitAnnotation records on the component, as <ref>@<commit>, the commit its ref pointed to when its git
+	// repository was last polled
+	PolledCommitAnnotation = annotationPrefix + "polled-commit"
+	// TriggeredCommitAnnotation records on the component, as <ref>@<commit>, the commit of its ref whose build was triggered
+	// by a push webhook or by polling its git repository, the build checking out this commit rather than the head of the ref
+	TriggeredCommitAnnotation = annotationPrefix + "triggered-commit"
+	// CommitAnnotation records on TaskRuns the SHA of the commit they built, when known
+	CommitAnnotation = annotationPrefix + "commit"
+	// PodConfigHashAnnotation records on the generated pod template the hash of the annotations it was generated from
+	PodConfigHashAnnotation = annotationPrefix + "pod-config-hash"
+	// TaskSpecHashAnnotation records on the generated Task the hash of its spec, so that it's only updated when it changes
+	TaskSpecHashAnnotation = annotationPrefix + "task-spec-hash"
+	// LinkedSecretsAnnotation records on the build service account of the component, as a comma-separated list, the
+	// Secrets linked to it by the operator, so that the ones the component doesn't use anymore are unlinked
+	LinkedSecretsAnnotation = annotationPrefix + "linked-secrets"
+)
+
+// podConfigAnnotations lists the annotations shaping the generated pod template: the pod template is re-generated when
+// any of their values change
+var podConfigAnnotations = []string{VolumesAnnotation, VolumeMountsAnnotation, ContainersAnnotation, InitContainersAnnotation, SchedulingAnnotation, PodSecurityAnnotation, ImagePullSecretsAnnotation,
+	PinImagesAnnotation, ImagePullPolicyAnnotation, ImageAnnotation}
+
+func getAnnotation(c *component.Component, key string) (string, bool) {
+	if c.Annotations == nil {
+		return "", false
+	}
+	value, found := c.Annotations[key]
+	return value, found && len(value) > 0
+}
+
+// decodeAnnotation unmarshals the JSON value of the specified annotation into target, returning false if the annotation
+// isn't set on the component
+func decodeAnnotation(c *component.Component, key string, target interface{}) (bool, error) {
+	value, found := getAnnotation(c, key)
+	if !found {
+		return false, nil
+	}
+	// reject unknown fields so that typos or unsupported settings don't go unnoticed
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(target); err != nil {
+		return true, fmt.Errorf("invalid '%s' annotation on component '%s': %s", key, c.Name, err.Error())
+	}
+	return true, nil
+}
+
+// podConfigHash computes a hash of the values of the podConfigAnnotations set on the component, along with its pod
+// security, empty if none is set and its pods are unrestricted so that the pod templates generated before these
+// annotations were supported are left untouched
+func podConfigHash(c *component.Component) string {
+	hash := sha256.New()
+	set := false
+	for _, key := range podConfigAnnotations {
+		if value, found := getAnnotation(c, key); found && key != PodSecurityAnnotation {
+			_, _ = fmt.Fprintf(hash, "%s=%s\n", key, value)
+			set = true
+		}
+	}
+	// the components restricted by default are rolled out with the restricted security context
+	if security, err := podSecurity(c); err == nil && security == RestrictedPodSecurity {
+		_, _ = fmt.Fprintf(hash, "%s=%s\n", PodSecurityAnnotation, security)
+		set = true
+	}
+	if !set {
+		return ""
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil))
+}
+
+// podTemplateAnnotations returns the annotations to set on the generated pod template
+func podTemplateAnnotations(c *component.Component) map[string]string {
+	annotations := make(map[string]string, 1)
+	if hash := podConfigHash(c); len(hash) > 0 {
+		annotations[PodConfigHashAnnotation] = hash
+	}
+	return annotations
+}
@@ -3,6 +3,7 @@ package component
 import (
 	component "halkyon.io/api/component/v1beta1"
 	framework "halkyon.io/operator-framework"
+	"halkyon.io/operator/pkg/registry"
 	"k8s.io/api/apps/v1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -18,7 +19,11 @@ func (res deployment) installBuild(empty bool) (runtime.Object, error) {
 		ls := getAppLabels(c)
 
 		// create runtime container using built image (= created by the Tekton build task)
-		runtimeContainer, err := getRuntimeContainerFor(c)
+		image, pinned, build, err := builtImage(c)
+		if err != nil {
+			return nil, err
+		}
+		runtimeContainer, err := getRuntimeContainerFor(c, image, pinned)
 		if err != nil {
 			return nil, err
 		}
@@ -42,6 +47,21 @@ func (res deployment) installBuild(empty bool) (runtime.Object, error) {
 			}}
 		}
 
+		// add the volumes requested by the user
+		userVolumes, userMounts, err := getUserVolumes(c)
+		if err != nil {
+			return nil, err
+		}
+		runtimeContainer.VolumeMounts = append(runtimeContainer.VolumeMounts, userMounts...)
+		sidecars, initContainers, err := getUserContainers(c)
+		if err != nil {
+			return nil, err
+		}
+		scheduling, err := getScheduling(c, SchedulingAnnotation)
+		if err != nil {
+			return nil, err
+		}
+
 		dep.ObjectMeta = metav1.ObjectMeta{
 			Name:      res.Name(),
 			Namespace: c.Namespace,
@@ -56,30 +76,99 @@ func (res deployment) installBuild(empty bool) (runtime.Object, error) {
 			},
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: ls,
-					Name:   c.Name,
+					Labels:      ls,
+					Name:        c.Name,
+					Annotations: podTemplateAnnotations(c),
 				},
 				Spec: corev1.PodSpec{
-					Containers: []corev1.Container{runtimeContainer},
+					Containers:       append([]corev1.Container{runtimeContainer}, sidecars...),
+					InitContainers:   initContainers,
+					ImagePullSecrets: imagePullSecrets(c),
+					Volumes:          userVolumes,
 				}},
 		}
-
+		if len(build) > 0 {
+			dep.Spec.Template.Annotations[BuildAnnotation] = build
+		}
+		scheduling.applyTo(&dep.Spec.Template.Spec)
+		if err := applyPodSecurity(c, &dep.Spec.Template); err != nil {
+			return nil, err
+		}
 	}
 
 	// Set Component instance as the owner and controller
 	return dep, nil
 }
 
-func getRuntimeContainerFor(component *component.Component) (corev1.Container, error) {
+func getRuntimeContainerFor(component *component.Component, image string, pinned bool) (corev1.Container, error) {
+	env, err := populatePodEnvVar(component)
+	if err != nil {
+		return corev1.Container{}, err
+	}
+	envFrom, err := populatePodEnvFrom(component, nil)
+	if err != nil {
+		return corev1.Container{}, err
+	}
+	pullPolicy, err := imagePullPolicy(component, pinned)
+	if err != nil {
+		return corev1.Container{}, err
+	}
+
 	container := corev1.Container{
-		Env:             populatePodEnvVar(component),
-		Image:           dockerImageURL(component),
-		ImagePullPolicy: corev1.PullAlways,
+		Env:             env,
+		EnvFrom:         envFrom,
+		Image:           image,
+		ImagePullPolicy: pullPolicy,
 		Name:            component.Name,
 	}
 	return container, nil
 }
 
+// builtImage returns the image to deploy for the component, along with whether it's pinned by digest and the name of the
+// build which produced it, if any. The image produced by the deployed build is pinned to the digest recorded for it or,
+// failing that, to the one its tag points to since the build completed. A build explicitly pinned using the
+// DeployedBuildAnnotation is always deployed by digest since its tag might point to a newer build. Otherwise, the image
+// the tag specified using the DeployedTagAnnotation points to is deployed, if any.
+func builtImage(component *component.Component) (image string, pinned bool, build string, err error) {
+	if tag, tagged, _ := deployedTag(component); tagged {
+		if _, rollback := getAnnotation(component, DeployedBuildAnnotation); !rollback {
+			return taggedImage(component, tag)
+		}
+	}
+	verify, err := registryTLS(component)
+	if err != nil {
+		return "", false, "", err
+	}
+	tr, e := deployedBuild(component)
+	if e != nil || tr == nil {
+		if image, err = dockerImageURL(component); err != nil {
+			return "", false, "", err
+		}
+		image, pinned = pinImage(component, image, imagePullSecrets(component), verify)
+		return image, pinned, "", nil
+	}
+
+	if image, err = builtImageName(component, tr); err != nil {
+		return "", false, "", err
+	}
+	_, rollback := getAnnotation(component, DeployedBuildAnnotation)
+	if pin, found := getAnnotation(component, PinImagesAnnotation); found && pin == "false" && !rollback {
+		return image, false, tr.Name, nil
+	}
+	if image, pinned, err = builtImageFor(component, tr); err != nil || pinned {
+		return image, pinned, tr.Name, err
+	}
+	digest, e := imageResolver.DigestSince(image, registryKeychain(component.Namespace, imagePullSecrets(component)), verify, tr.Status.CompletionTime.Time)
+	if e != nil {
+		return image, false, tr.Name, nil
+	}
+	ref, e := registry.ParseReference(image)
+	if e != nil {
+		return image, false, tr.Name, nil
+	}
+	return ref.WithDigest(digest), true, tr.Name, nil
+}
+
 func updateEnv(envs []corev1.EnvVar, jarName string) []corev1.EnvVar {
 	newEnvs := []corev1.EnvVar{}
 	for _, s := range envs {
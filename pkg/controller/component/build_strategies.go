@@ -0,0 +1,203 @@
+package component
+
+import (
+	"fmt"
+	"halkyon.io/api/component/v1beta1"
+	"halkyon.io/operator/pkg/tekton"
+	"sort"
+	"strconv"
+)
+
+const (
+	// S2IBuildType builds the component's image using source-to-image, the default
+	S2IBuildType = "s2i"
+	// DockerBuildType builds the component's image from a Dockerfile found in its git repository
+	DockerBuildType = "docker"
+	// BuildpacksBuildType builds the component's image using Cloud Native Buildpacks
+	BuildpacksBuildType      = "buildpacks"
+	defaultDockerfile        = "Dockerfile"
+	defaultBuildpacksBuilder = "paketobuildpacks/builder:base"
+	// defaultS2IScriptsURL is the location of the s2i scripts in the default, Maven, s2i builder image
+	defaultS2IScriptsURL = "image:///usr/local/s2i"
+	defaultS2ILogLevel   = 5
+)
+
+// buildType returns the validated type of the component's build, S2IBuildType if not specified
+func buildType(c *v1beta1.Component) (string, error) {
+	switch c.Spec.BuildConfig.Type {
+	case "", S2IBuildType:
+		return S2IBuildType, nil
+	case DockerBuildType, BuildpacksBuildType:
+		return c.Spec.BuildConfig.Type, nil
+	default:
+		return "", fmt.Errorf("unsupported '%s' build type for component '%s', must be one of: %s, %s, %s", c.Spec.BuildConfig.Type, c.Name, S2IBuildType, DockerBuildType, BuildpacksBuildType)
+	}
+}
+
+func isDockerBuild(c *v1beta1.Component) bool {
+	t, _ := buildType(c)
+	return t == DockerBuildType
+}
+
+func isBuildpacksBuild(c *v1beta1.Component) bool {
+	t, _ := buildType(c)
+	return t == BuildpacksBuildType
+}
+
+// buildpacksBuilder returns the builder image used by buildpacks builds: the one specified by the component using the
+// BuildpacksBuilderAnnotation, else the default one of its runtime, else defaultBuildpacksBuilder
+func buildpacksBuilder(c *v1beta1.Component) (string, error) {
+	if builder, found := getAnnotation(c, BuildpacksBuilderAnnotation); found {
+		return builder, nil
+	}
+	runtime, err := getImageInfo(c)
+	if err != nil {
+		return "", err
+	}
+	if len(runtime.builder) > 0 {
+		return runtime.builder, nil
+	}
+	return defaultBuildpacksBuilder, nil
+}
+
+// s2iBuilder returns the builder image used by s2i builds along with the location of its s2i scripts: the base image
+// specified by the component's buildConfig, else the builder provided by its runtime using the S2IBuilderAnnotation,
+// else the default Maven one. The scripts of the builders provided by runtimes are located using their labels.
+func s2iBuilder(c *v1beta1.Component) (string, string, error) {
+	if len(c.Spec.BuildConfig.BaseImage) > 0 {
+		return c.Spec.BuildConfig.BaseImage, defaultS2IScriptsURL, nil
+	}
+	runtime, err := getImageInfo(c)
+	if err != nil {
+		return "", "", err
+	}
+	if len(runtime.s2iBuilder) > 0 {
+		return runtime.s2iBuilder, "", nil
+	}
+	return baseImage(c), defaultS2IScriptsURL, nil
+}
+
+// s2iBuildEnv returns the env passed to s2i builds as --env flags, sorted by name: the env configuring the default Maven
+// builder, overridden by the build env provided by the component's runtime using the BuildEnvAnnotation, itself
+// overridden by the build env specified by the component using the same annotation
+func s2iBuildEnv(c *v1beta1.Component) ([]string, error) {
+	runtime, err := getImageInfo(c)
+	if err != nil {
+		return nil, err
+	}
+	overrides, err := componentBuildEnv(c)
+	if err != nil {
+		return nil, err
+	}
+	env := map[string]string{
+		"MAVEN_ARGS_APPEND":             "-pl " + moduleDirName(c),
+		"MAVEN_S2I_ARTIFACT_DIRS":       moduleDirName(c) + "/target",
+		"S2I_SOURCE_DEPLOYMENTS_FILTER": "*.jar",
+	}
+	for _, values := range []map[string]string{runtime.buildEnv, overrides} {
+		for name, value := range values {
+			env[name] = value
+		}
+	}
+	return flags("--env", env), nil
+}
+
+// componentBuildEnv returns the build env specified by the component using the BuildEnvAnnotation
+func componentBuildEnv(c *v1beta1.Component) (map[string]string, error) {
+	env := make(map[string]string)
+	if _, err := decodeAnnotation(c, BuildEnvAnnotation, &env); err != nil {
+		return nil, err
+	}
+	if _, found := env[""]; found {
+		return nil, fmt.Errorf("invalid '%s' annotation on component '%s': env var names cannot be empty", BuildEnvAnnotation, c.Name)
+	}
+	return env, nil
+}
+
+// s2iLogLevel returns the validated log level of s2i builds, defaultS2ILogLevel if not specified
+func s2iLogLevel(c *v1beta1.Component) (int, error) {
+	value, found := getAnnotation(c, BuildLogLevelAnnotation)
+	if !found {
+		return defaultS2ILogLevel, nil
+	}
+	level, err := strconv.Atoi(value)
+	if err != nil || level < 0 || level > 5 {
+		return 0, fmt.Errorf("invalid '%s' annotation on component '%s': must be a number between 0 and 5", BuildLogLevelAnnotation, c.Name)
+	}
+	return level, nil
+}
+
+// dockerfile returns the path of the Dockerfile to build, relative to the component's context path
+func dockerfile(c *v1beta1.Component) string {
+	if path, found := getAnnotation(c, DockerfileAnnotation); found {
+		return path
+	}
+	return defaultDockerfile
+}
+
+// buildArgs returns the build args passed to Dockerfile builds as --build-arg flags, sorted by name
+func buildArgs(c *v1beta1.Component) ([]string, error) {
+	args := make(map[string]string)
+	if _, err := decodeAnnotation(c, BuildArgsAnnotation, &args); err != nil {
+		return nil, err
+	}
+	return flags("--build-arg", args), nil
+}
+
+// flags returns the specified values as name=value arguments of the specified flag, sorted by name
+func flags(flag string, values map[string]string) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	flags := make([]string, 0, 2*len(names))
+	for _, name := range names {
+		flags = append(flags, flag, name+"="+values[name])
+	}
+	return flags
+}
+
+// buildParams returns the params to pass to the Task building the component, which depend on its build type
+func buildParams(c *v1beta1.Component) ([]tekton.Param, error) {
+	if isBuildpacksBuild(c) {
+		builder, err := buildpacksBuilder(c)
+		if err != nil {
+			return nil, err
+		}
+		return []tekton.Param{
+			{Name: "builderImage", Value: tekton.NewString(builder)},
+			{Name: "contextPath", Value: tekton.NewString(contextPath(c))},
+		}, nil
+	}
+	if isDockerBuild(c) {
+		args, err := buildArgs(c)
+		if err != nil {
+			return nil, err
+		}
+		return []tekton.Param{
+			{Name: "contextPath", Value: tekton.NewString(contextPath(c))},
+			{Name: "dockerfile", Value: tekton.NewString(dockerfile(c))},
+			{Name: "buildArgs", Value: tekton.NewArray(args...)},
+		}, nil
+	}
+	builder, scriptsURL, err := s2iBuilder(c)
+	if err != nil {
+		return nil, err
+	}
+	env, err := s2iBuildEnv(c)
+	if err != nil {
+		return nil, err
+	}
+	logLevel, err := s2iLogLevel(c)
+	if err != nil {
+		return nil, err
+	}
+	return []tekton.Param{
+		{Name: "baseImage", Value: tekton.NewString(builder)},
+		{Name: "scriptsUrl", Value: tekton.NewString(scriptsURL)},
+		{Name: "contextPath", Value: tekton.NewString(contextPath(c))},
+		{Name: "logLevel", Value: tekton.NewString(strconv.Itoa(logLevel))},
+		{Name: "buildEnv", Value: tekton.NewArray(env...)},
+	}, nil
+}
@@ -0,0 +1,227 @@
+package component
+
+import (
+	"context"
+	"fmt"
+	component "halkyon.io/api/component/v1beta1"
+	framework "halkyon.io/operator-framework"
+	"halkyon.io/operator/pkg/tekton"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// NoBuildCache, the default, runs each build with an empty cache
+	NoBuildCache = "none"
+	// ComponentBuildCache keeps the build cache in a PVC owned by the component
+	ComponentBuildCache = "component"
+	// NamespaceBuildCache keeps the build cache in a PVC shared by the components of the namespace
+	NamespaceBuildCache = "namespace"
+	// SharedBuildCacheName is the name of the PVC holding the build cache shared by the components of a namespace
+	SharedBuildCacheName     = "halkyon-build-cache"
+	defaultBuildCacheSize    = "5Gi"
+	sharedBuildCacheLabelKey = "halkyon.io/build-cache"
+)
+
+// buildCache returns the validated build cache mode of the component, NoBuildCache if not specified
+func buildCache(c *component.Component) (string, error) {
+	mode, found := getAnnotation(c, BuildCacheAnnotation)
+	if !found {
+		return NoBuildCache, nil
+	}
+	switch mode {
+	case NoBuildCache, ComponentBuildCache, NamespaceBuildCache:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unsupported '%s' build cache for component '%s', must be one of: %s, %s, %s", mode, c.Name, NoBuildCache, ComponentBuildCache, NamespaceBuildCache)
+	}
+}
+
+// buildCacheSize returns the validated size of the build cache PVC, defaultBuildCacheSize if not specified
+func buildCacheSize(c *component.Component) (resource.Quantity, error) {
+	size, found := getAnnotation(c, BuildCacheSizeAnnotation)
+	if !found {
+		size = defaultBuildCacheSize
+	}
+	quantity, err := resource.ParseQuantity(size)
+	if err != nil {
+		return quantity, fmt.Errorf("invalid '%s' annotation on component '%s': %s", BuildCacheSizeAnnotation, c.Name, err.Error())
+	}
+	return quantity, nil
+}
+
+// BuildCacheName returns the name of the PVC holding the build cache of the specified component
+func BuildCacheName(c *component.Component) string {
+	return c.Name + "-build-cache"
+}
+
+// buildCacheWorkspace binds the cache workspace of the build to the PVC holding the component's build cache, if any,
+// builds starting with an empty cache otherwise
+func buildCacheWorkspace(c *component.Component) tekton.WorkspaceBinding {
+	binding := tekton.WorkspaceBinding{Name: cacheWorkspace}
+	switch mode, _ := buildCache(c); mode {
+	case ComponentBuildCache:
+		binding.PersistentVolumeClaim = &corev1.PersistentVolumeClaimVolumeSource{ClaimName: BuildCacheName(c)}
+	case NamespaceBuildCache:
+		binding.PersistentVolumeClaim = &corev1.PersistentVolumeClaimVolumeSource{ClaimName: SharedBuildCacheName}
+	default:
+		binding.EmptyDir = &corev1.EmptyDirVolumeSource{}
+	}
+	return binding
+}
+
+// purgeBuildCache returns whether the current build of the component needs to purge the build cache before building,
+// which is the case when the value of the PurgeBuildCacheAnnotation changed since the previous build
+func purgeBuildCache(c *component.Component) (bool, error) {
+	purge, found := getAnnotation(c, PurgeBuildCacheAnnotation)
+	if !found {
+		return false, nil
+	}
+	builds, err := listBuilds(c)
+	if err != nil {
+		return false, err
+	}
+	current := buildName(c)
+	for _, build := range builds {
+		if build.Name != current {
+			return build.Annotations[PurgeBuildCacheAnnotation] != purge, nil
+		}
+	}
+	// the first build of the component has nothing to purge
+	return false, nil
+}
+
+// checkSharedBuildCache returns an error postponing the current build of the component, until the builds of the other
+// components complete, if it would purge the build cache shared by the namespace while they use it or use it while one of
+// them purges it. Builds which already started are left alone.
+func checkSharedBuildCache(c *component.Component, purge bool) error {
+	if mode, _ := buildCache(c); mode != NamespaceBuildCache {
+		return nil
+	}
+	taskRuns := &tekton.TaskRunList{}
+	lo := &client.ListOptions{}
+	lo.InNamespace(c.Namespace)
+	if err := framework.Helper.Client.List(context.TODO(), lo, taskRuns); err != nil {
+		return err
+	}
+	current := buildName(c)
+	running := make([]*tekton.TaskRun, 0, len(taskRuns.Items))
+	for i := range taskRuns.Items {
+		tr := &taskRuns.Items[i]
+		if tr.Name == current {
+			return nil
+		}
+		if !isCompleted(tr) && usesSharedBuildCache(tr) {
+			running = append(running, tr)
+		}
+	}
+	for _, tr := range running {
+		if purge {
+			return fmt.Errorf("the '%s' build cache shared by the namespace can't be purged while the '%s' build uses it, the build of component '%s' starts once it completes", SharedBuildCacheName, tr.Name, c.Name)
+		}
+		if purgesBuildCache(tr) {
+			return fmt.Errorf("the '%s' build cache shared by the namespace is being purged by the '%s' build, the build of component '%s' starts once it completes", SharedBuildCacheName, tr.Name, c.Name)
+		}
+	}
+	return nil
+}
+
+func usesSharedBuildCache(tr *tekton.TaskRun) bool {
+	for _, workspace := range tr.Spec.Workspaces {
+		if workspace.PersistentVolumeClaim != nil && workspace.PersistentVolumeClaim.ClaimName == SharedBuildCacheName {
+			return true
+		}
+	}
+	return false
+}
+
+func purgesBuildCache(tr *tekton.TaskRun) bool {
+	for _, param := range tr.Spec.Params {
+		if param.Name == "purgeCache" {
+			return param.Value.StringVal == "true"
+		}
+	}
+	return false
+}
+
+// ensureSharedBuildCache creates the build cache PVC shared by the components of the namespace if it doesn't exist yet.
+// The PVC isn't owned by any component so that it outlives them: it can also be created beforehand, e.g. to use a
+// specific storage class or the ReadWriteMany access mode so that builds of different components can run concurrently
+// on different nodes.
+func ensureSharedBuildCache(c *component.Component) error {
+	if mode, _ := buildCache(c); mode != NamespaceBuildCache {
+		return nil
+	}
+	pvc := &corev1.PersistentVolumeClaim{}
+	err := framework.Helper.Client.Get(context.TODO(), types.NamespacedName{Name: SharedBuildCacheName, Namespace: c.Namespace}, pvc)
+	if err == nil || !errors.IsNotFound(err) {
+		return err
+	}
+	size, err := buildCacheSize(c)
+	if err != nil {
+		return err
+	}
+	pvc.ObjectMeta = metav1.ObjectMeta{
+		Name:      SharedBuildCacheName,
+		Namespace: c.Namespace,
+		Labels:    map[string]string{sharedBuildCacheLabelKey: NamespaceBuildCache},
+	}
+	pvc.Spec = buildCacheSpec(size)
+	if err = framework.Helper.Client.Create(context.TODO(), pvc); errors.IsAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
+
+func buildCacheSpec(size resource.Quantity) corev1.PersistentVolumeClaimSpec {
+	return corev1.PersistentVolumeClaimSpec{
+		AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceStorage: size,
+			},
+		},
+	}
+}
+
+type buildCachePvc struct {
+	base
+}
+
+var _ framework.DependentResource = &buildCachePvc{}
+
+func newBuildCachePvc(owner *component.Component) buildCachePvc {
+	config := framework.NewConfig(corev1.SchemeGroupVersion.WithKind("PersistentVolumeClaim"))
+	mode, _ := buildCache(owner)
+	config.Created = component.BuildDeploymentMode == owner.Spec.DeploymentMode && mode == ComponentBuildCache
+	p := buildCachePvc{base: newConfiguredBaseDependent(owner, config)}
+	p.NameFn = p.Name
+	return p
+}
+
+func (res buildCachePvc) Build(empty bool) (runtime.Object, error) {
+	pvc := &corev1.PersistentVolumeClaim{}
+	if !empty {
+		c := res.ownerAsComponent()
+		size, err := buildCacheSize(c)
+		if err != nil {
+			return nil, err
+		}
+		pvc.ObjectMeta = metav1.ObjectMeta{
+			Name:      res.Name(),
+			Namespace: c.Namespace,
+			Labels:    getBuildLabels(c.Name),
+		}
+		pvc.Spec = buildCacheSpec(size)
+	}
+	return pvc, nil
+}
+
+func (res buildCachePvc) Name() string {
+	return BuildCacheName(res.ownerAsComponent())
+}
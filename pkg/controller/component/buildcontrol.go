@@ -0,0 +1,209 @@
+package component
+
+import (
+	"context"
+	"fmt"
+	"halkyon.io/api/component/v1beta1"
+	framework "halkyon.io/operator-framework"
+	"halkyon.io/operator/pkg/tekton"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultBuildTimeout = time.Hour
+	// defaultBuildRetries is the number of times a build failing because of the infrastructure is retried when the
+	// component doesn't set the BuildRetriesAnnotation
+	defaultBuildRetries = 2
+	// buildRetryBackoff is the delay before the first retry of a failed build, doubled for each subsequent retry
+	buildRetryBackoff = 30 * time.Second
+	// BuildCancelledReason and BuildTimedOutReason are the reasons of the build condition when the build was cancelled
+	// or didn't complete in time
+	BuildCancelledReason = "BuildCancelled"
+	BuildTimedOutReason  = "BuildTimedOut"
+)
+
+var (
+	// infrastructureReasons are the reasons of the builds whose pod couldn't run or failed because of its node
+	infrastructureReasons = map[string]bool{
+		tekton.TaskRunReasonImagePullFailed:       true,
+		tekton.TaskRunReasonPodCreationFailed:     true,
+		tekton.TaskRunReasonExceededNodeResources: true,
+		"Evicted":   true,
+		"NodeLost":  true,
+		"Preempted": true,
+	}
+	// podFailure matches the messages of the builds whose pod failed because of its node, which Tekton reports with its
+	// generic reason
+	podFailure = regexp.MustCompile(`(?i)evicted|node ?lost|unresponsive|preempt|node shutdown`)
+	// registryFailure matches the server errors and connection failures reported when pushing or tagging images
+	registryFailure = regexp.MustCompile(`(?i)status( code)?:? *5\d\d\b|\b5\d\d (internal server error|bad gateway|service unavailable|gateway time-?out)|connection refused|connection reset|broken pipe|unexpected eof|i/o timeout|tls handshake timeout|timeout awaiting response headers|client\.timeout exceeded|network is unreachable|temporary failure in name resolution`)
+)
+
+// buildTimeout returns the validated duration after which the component's builds fail, defaultBuildTimeout if not
+// specified
+func buildTimeout(c *v1beta1.Component) (time.Duration, error) {
+	value, found := getAnnotation(c, BuildTimeoutAnnotation)
+	if !found {
+		return defaultBuildTimeout, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid '%s' annotation on component '%s': must be a positive duration", BuildTimeoutAnnotation, c.Name)
+	}
+	return timeout, nil
+}
+
+// buildRetries returns the validated number of times a build of the component failing because of the infrastructure
+// is retried
+func buildRetries(c *v1beta1.Component) (int, error) {
+	value, found := getAnnotation(c, BuildRetriesAnnotation)
+	if !found {
+		return defaultBuildRetries, nil
+	}
+	retries, err := strconv.Atoi(value)
+	if err != nil || retries < 0 {
+		return 0, fmt.Errorf("invalid '%s' annotation on component '%s': must be a non-negative number", BuildRetriesAnnotation, c.Name)
+	}
+	return retries, nil
+}
+
+// buildAttempt returns the attempt recorded with the BuildAttemptAnnotation on the specified object, 1 if none
+func buildAttempt(meta metav1.Object) int {
+	attempt, err := strconv.Atoi(meta.GetAnnotations()[BuildAttemptAnnotation])
+	if err != nil || attempt < 1 {
+		return 1
+	}
+	return attempt
+}
+
+// isCompleted returns whether the specified build completed, successfully or not
+func isCompleted(tr *tekton.TaskRun) bool {
+	succeeded := tr.Status.GetCondition(apis.ConditionSucceeded)
+	return succeeded != nil && !succeeded.IsUnknown()
+}
+
+// isCancelled and isTimedOut return whether the specified build was cancelled or didn't complete in time
+func isCancelled(tr *tekton.TaskRun) bool {
+	succeeded := tr.Status.GetCondition(apis.ConditionSucceeded)
+	return succeeded != nil && succeeded.IsFalse() && succeeded.Reason == tekton.TaskRunReasonCancelled
+}
+
+func isTimedOut(tr *tekton.TaskRun) bool {
+	succeeded := tr.Status.GetCondition(apis.ConditionSucceeded)
+	return succeeded != nil && succeeded.IsFalse() && succeeded.Reason == tekton.TaskRunReasonTimedOut
+}
+
+// failedStep returns the state of the first step of the specified build which exited with an error, nil if none did
+func failedStep(tr *tekton.TaskRun) *tekton.StepState {
+	for i, step := range tr.Status.Steps {
+		if step.Terminated != nil && step.Terminated.ExitCode != 0 {
+			return &tr.Status.Steps[i]
+		}
+	}
+	return nil
+}
+
+// isInfrastructureFailure returns whether the specified build failed because of the infrastructure rather than because
+// of the project being built: its pod couldn't pull a step image, be created or be scheduled, was evicted, lost along
+// with its node or preempted, or the registry couldn't be reached or answered with a server error when pushing or
+// tagging the image. The reason of the failure is checked first, the messages only being matched when it is generic: the
+// message of the build for pod failures, else the termination message and the log of the failed step.
+func isInfrastructureFailure(tr *tekton.TaskRun) bool {
+	succeeded := tr.Status.GetCondition(apis.ConditionSucceeded)
+	if succeeded == nil || !succeeded.IsFalse() || isCancelled(tr) || isTimedOut(tr) {
+		return false
+	}
+	if infrastructureReasons[succeeded.Reason] {
+		return true
+	}
+	if succeeded.Reason != tekton.TaskRunReasonFailed {
+		return false
+	}
+	if strings.Contains(strings.ToLower(succeeded.Message), "evicted") {
+		return true
+	}
+	step := failedStep(tr)
+	if step == nil {
+		return podFailure.MatchString(succeeded.Message)
+	}
+	if step.Name != pushStepName && step.Name != tagStepName {
+		return false
+	}
+	output := step.Terminated.Message
+	if failure := buildFailure(tr); failure != nil {
+		output += "\n" + failure.Log
+	}
+	return registryFailure.MatchString(output)
+}
+
+// retryBuild starts a new attempt of the component's current build if it failed because of the infrastructure and can
+// still be retried, recording the attempt with the BuildAttemptAnnotation. Attempts are delayed using an exponential
+// backoff. Returns whether the component was modified and needs to be updated, and whether a retry is pending.
+func retryBuild(c *v1beta1.Component) (retried bool, pending bool, err error) {
+	retries, err := buildRetries(c)
+	if err != nil {
+		return false, false, err
+	}
+	tr := &tekton.TaskRun{}
+	if _, err := framework.Helper.Fetch(buildName(c), c.Namespace, tr); err != nil {
+		if errors.IsNotFound(err) {
+			return false, false, nil
+		}
+		return false, false, err
+	}
+	attempt := buildAttempt(c)
+	if retry, pending := retryDue(tr, attempt, retries, time.Now()); !retry {
+		return false, pending, nil
+	}
+	c.Annotations[BuildNumberAnnotation] = strconv.Itoa(nextBuildNumber(c))
+	c.Annotations[BuildAttemptAnnotation] = strconv.Itoa(attempt + 1)
+	log.Info(fmt.Sprintf("retrying '%s' build which failed because of the infrastructure, attempt %d of %d", tr.Name, attempt+1, retries+1), "component", c.Name, "namespace", c.Namespace)
+	return true, false, nil
+}
+
+// retryDue returns whether the specified attempt of a build, which can be retried the specified number of times, is to
+// be retried at the specified time, and whether a retry is pending because its backoff delay didn't elapse yet
+func retryDue(tr *tekton.TaskRun, attempt, retries int, now time.Time) (retry bool, pending bool) {
+	if attempt > retries || !isInfrastructureFailure(tr) {
+		return false, false
+	}
+	if tr.Status.CompletionTime != nil {
+		backoff := buildRetryBackoff * time.Duration(1<<uint(attempt-1))
+		if now.Sub(tr.Status.CompletionTime.Time) < backoff {
+			return false, true
+		}
+	}
+	return true, false
+}
+
+// cancelBuild cancels the build named by the CancelBuildAnnotation, if it's still running
+func cancelBuild(c *v1beta1.Component) error {
+	name, found := getAnnotation(c, CancelBuildAnnotation)
+	if !found {
+		return nil
+	}
+	tr := &tekton.TaskRun{}
+	if _, err := framework.Helper.Fetch(name, c.Namespace, tr); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if tr.Labels["component_cr"] != c.Name {
+		return fmt.Errorf("'%s' build to cancel didn't build component '%s'", name, c.Name)
+	}
+	if isCompleted(tr) {
+		return nil
+	}
+	if tr.Spec.Status == tekton.TaskRunSpecStatusCancelled {
+		return nil
+	}
+	tr.Spec.Status = tekton.TaskRunSpecStatusCancelled
+	return framework.Helper.Client.Update(context.TODO(), tr)
+}
@@ -0,0 +1,165 @@
+package component
+
+import (
+	"encoding/json"
+	"halkyon.io/operator/pkg/tekton"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+	duckv1beta1 "knative.dev/pkg/apis/duck/v1beta1"
+	"testing"
+	"time"
+)
+
+// newFailedBuild returns a build which failed with the specified reason and message, the specified step exiting with an
+// error and the specified termination message if any, and the specified log recorded as its failure
+func newFailedBuild(reason, message, step, terminationMessage, log string) *tekton.TaskRun {
+	tr := newTestBuild(1)
+	tr.Status.Status = duckv1beta1.Status{Conditions: duckv1beta1.Conditions{{
+		Type:    apis.ConditionSucceeded,
+		Status:  corev1.ConditionFalse,
+		Reason:  reason,
+		Message: message,
+	}}}
+	if len(step) > 0 {
+		tr.Status.Steps = []tekton.StepState{{Name: step, ContainerState: corev1.ContainerState{
+			Terminated: &corev1.ContainerStateTerminated{ExitCode: 1, Message: terminationMessage},
+		}}}
+		failure, _ := json.Marshal(BuildFailure{Step: step, ExitCode: 1, Log: log})
+		tr.Annotations[BuildFailureAnnotation] = string(failure)
+	}
+	return &tr
+}
+
+func TestIsInfrastructureFailure(t *testing.T) {
+	tests := []struct {
+		name     string
+		build    *tekton.TaskRun
+		expected bool
+	}{
+		{
+			name:     "evicted pod",
+			build:    newFailedBuild("Failed", "The node was low on resource: ephemeral-storage. Pod was evicted", "", "", ""),
+			expected: true,
+		},
+		{
+			name:     "evicted pod while building",
+			build:    newFailedBuild("Failed", "pod has been evicted", "build", "", "BUILD FAILURE"),
+			expected: true,
+		},
+		{
+			name:     "lost node",
+			build:    newFailedBuild("NodeLost", "Node worker-1 which was running pod backend-build-1-pod is unresponsive", "", "", ""),
+			expected: true,
+		},
+		{
+			name:     "preempted pod",
+			build:    newFailedBuild("Failed", "Preempted in order to admit critical pod", "", "", ""),
+			expected: true,
+		},
+		{
+			name:     "image pull failure",
+			build:    newFailedBuild(tekton.TaskRunReasonImagePullFailed, "the step \"build\" failed to pull the image", "", "", ""),
+			expected: true,
+		},
+		{
+			name:     "pod creation failure",
+			build:    newFailedBuild(tekton.TaskRunReasonPodCreationFailed, "failed to create task run pod \"backend-build-1\": admission webhook unavailable", "", "", ""),
+			expected: true,
+		},
+		{
+			name:     "unschedulable pod",
+			build:    newFailedBuild(tekton.TaskRunReasonExceededNodeResources, "TaskRun Pod exceeded available resources", "", "", ""),
+			expected: true,
+		},
+		{
+			name:     "evicted reason",
+			build:    newFailedBuild("Evicted", "The node was low on resource: memory.", "build", "", "BUILD FAILURE"),
+			expected: true,
+		},
+		{
+			name:  "invalid task run",
+			build: newFailedBuild("TaskRunValidationFailed", "invalid volume \"preempted-jobs\": not found", "", "", ""),
+		},
+		{
+			name:  "compilation failure",
+			build: newFailedBuild("Failed", "\"step-build\" exited with code 1", "build", "", "connection refused"),
+		},
+		{
+			name:     "registry server error when pushing",
+			build:    newFailedBuild("Failed", "\"step-push\" exited with code 1", pushStepName, "", "Error writing blob: received unexpected HTTP status: 503 Service Unavailable"),
+			expected: true,
+		},
+		{
+			name:     "unreachable registry when tagging",
+			build:    newFailedBuild("Failed", "\"step-tag\" exited with code 1", tagStepName, "", "dial tcp 10.0.0.1:5000: connect: connection refused"),
+			expected: true,
+		},
+		{
+			name:     "termination message",
+			build:    newFailedBuild("Failed", "\"step-push\" exited with code 1", pushStepName, "502 Bad Gateway", ""),
+			expected: true,
+		},
+		{
+			name:  "rejected credentials when pushing",
+			build: newFailedBuild("Failed", "\"step-push\" exited with code 1", pushStepName, "", "unauthorized: authentication required, status 401"),
+		},
+		{
+			name:  "push failure without log",
+			build: newFailedBuild("Failed", "\"step-push\" exited with code 1", pushStepName, "", ""),
+		},
+		{
+			name:  "cancelled",
+			build: newFailedBuild(tekton.TaskRunReasonCancelled, "TaskRun was cancelled, pod was evicted", "", "", ""),
+		},
+		{
+			name:  "timed out",
+			build: newFailedBuild(tekton.TaskRunReasonTimedOut, "TaskRun failed to finish within 1h0m0s", "", "", ""),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := isInfrastructureFailure(test.build); actual != test.expected {
+				t.Errorf("expected infrastructure failure to be %t, got %t", test.expected, actual)
+			}
+		})
+	}
+
+	running := newTestBuild(1)
+	if isInfrastructureFailure(&running) {
+		t.Errorf("expected a running build not to have failed")
+	}
+}
+
+func TestRetryDue(t *testing.T) {
+	now := time.Now()
+	evicted := func(completed time.Duration) *tekton.TaskRun {
+		tr := newFailedBuild("Failed", "Pod was evicted", "", "", "")
+		tr.Status.CompletionTime = &metav1.Time{Time: now.Add(-completed)}
+		return tr
+	}
+	tests := []struct {
+		name    string
+		build   *tekton.TaskRun
+		attempt int
+		retries int
+		retry   bool
+		pending bool
+	}{
+		{name: "first retry", build: evicted(time.Minute), attempt: 1, retries: 2, retry: true},
+		{name: "first retry backoff", build: evicted(10 * time.Second), attempt: 1, retries: 2, pending: true},
+		{name: "second retry backoff", build: evicted(45 * time.Second), attempt: 2, retries: 2, pending: true},
+		{name: "second retry", build: evicted(time.Minute), attempt: 2, retries: 2, retry: true},
+		{name: "no retries left", build: evicted(time.Hour), attempt: 3, retries: 2},
+		{name: "retries disabled", build: evicted(time.Hour), attempt: 1, retries: 0},
+		{name: "project failure", build: newFailedBuild("Failed", "\"step-build\" exited with code 1", "build", "", ""), attempt: 1, retries: 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			retry, pending := retryDue(test.build, test.attempt, test.retries, now)
+			if retry != test.retry || pending != test.pending {
+				t.Errorf("expected retry %t and pending %t, got %t and %t", test.retry, test.pending, retry, pending)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package component
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"halkyon.io/api/component/v1beta1"
+	framework "halkyon.io/operator-framework"
+	"halkyon.io/operator/pkg/logs"
+	"halkyon.io/operator/pkg/tekton"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"knative.dev/pkg/apis"
+)
+
+const (
+	// buildLogLines and buildLogMaxBytes bound the excerpt of the log of the failed step recorded for failed builds
+	buildLogLines    = 30
+	buildLogMaxBytes = 4096
+	// FailedStepAttributeKey, ExitCodeAttributeKey and LogAttributeKey are the condition attributes recording the step
+	// which made the build fail, its exit code and the end of its log
+	FailedStepAttributeKey = "FailedStep"
+	ExitCodeAttributeKey   = "ExitCode"
+	LogAttributeKey        = "Log"
+)
+
+// clientset performs the requests the controller-runtime client doesn't support, e.g. retrieving logs or patching
+var clientset kubernetes.Interface
+
+func kubeClient() kubernetes.Interface {
+	if clientset == nil {
+		clientset = kubernetes.NewForConfigOrDie(framework.Helper.Config)
+	}
+	return clientset
+}
+
+// BuildFailure describes why a build failed
+type BuildFailure struct {
+	Step     string `json:"step,omitempty"`
+	ExitCode int32  `json:"exitCode,omitempty"`
+	Log      string `json:"log,omitempty"`
+}
+
+// buildFailure returns the failure recorded on the specified build with the BuildFailureAnnotation, nil if none
+func buildFailure(tr *tekton.TaskRun) *BuildFailure {
+	value, found := tr.Annotations[BuildFailureAnnotation]
+	if !found {
+		return nil
+	}
+	failure := &BuildFailure{}
+	if json.Unmarshal([]byte(value), failure) != nil {
+		return nil
+	}
+	return failure
+}
+
+// recordBuildFailures records on the failed builds of the component, using the BuildFailureAnnotation, which step made
+// them fail along with its exit code and the end of its log, secrets being redacted. The log is retrieved once, while
+// the pod of the build still exists.
+func recordBuildFailures(c *v1beta1.Component, builds []tekton.TaskRun) error {
+	for i, build := range builds {
+		succeeded := build.Status.GetCondition(apis.ConditionSucceeded)
+		if succeeded == nil || !succeeded.IsFalse() || len(build.Annotations[BuildFailureAnnotation]) > 0 {
+			continue
+		}
+		failure := BuildFailure{}
+		if step := failedStep(&build); step != nil {
+			failure.Step = step.Name
+			failure.ExitCode = step.Terminated.ExitCode
+			failure.Log = stepLogExcerpt(c, &build, step)
+		}
+		value, err := json.Marshal(failure)
+		if err != nil {
+			return err
+		}
+		if builds[i].Annotations == nil {
+			builds[i].Annotations = make(map[string]string, 1)
+		}
+		builds[i].Annotations[BuildFailureAnnotation] = string(value)
+		if err := framework.Helper.Client.Update(context.TODO(), &builds[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stepLogExcerpt returns the end of the log of the specified step of the specified build, empty if it cannot be
+// retrieved, e.g. because the pod of the build was deleted
+func stepLogExcerpt(c *v1beta1.Component, tr *tekton.TaskRun, step *tekton.StepState) string {
+	if len(tr.Status.PodName) == 0 {
+		return ""
+	}
+	container := step.ContainerName
+	if len(container) == 0 {
+		container = "step-" + step.Name
+	}
+	lines := int64(buildLogLines)
+	data, err := kubeClient().CoreV1().Pods(tr.Namespace).GetLogs(tr.Status.PodName, &corev1.PodLogOptions{Container: container, TailLines: &lines}).DoRaw()
+	if err != nil {
+		log.Error(err, fmt.Sprintf("couldn't retrieve the log of the '%s' step of '%s' build", step.Name, tr.Name), "component", c.Name, "namespace", c.Namespace)
+		return ""
+	}
+	return logs.Excerpt(string(data), buildLogLines, buildLogMaxBytes, buildSecrets(c)...)
+}
+
+// buildSecrets returns the secret values the builds of the component have access to, which must be redacted from their
+// logs: the password or private key of its git credentials and the registry credentials of its pull secrets
+func buildSecrets(c *v1beta1.Component) []string {
+	secrets := gitSecrets(c)
+	for _, ref := range imagePullSecrets(c) {
+		secret := &corev1.Secret{}
+		if err := framework.Helper.Client.Get(context.TODO(), types.NamespacedName{Name: ref.Name, Namespace: c.Namespace}, secret); err != nil {
+			continue
+		}
+		for _, auth := range dockerConfigAuths(secret) {
+			secrets = append(secrets, auth.Password, auth.Auth)
+		}
+	}
+	return secrets
+}
@@ -0,0 +1,436 @@
+package component
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"halkyon.io/api/component/v1beta1"
+	"halkyon.io/operator-framework"
+	"halkyon.io/operator/pkg/git"
+	"halkyon.io/operator/pkg/registry"
+	"halkyon.io/operator/pkg/tekton"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// defaultBuildHistoryLimit is the number of builds kept when the component doesn't set the BuildHistoryLimitAnnotation
+	defaultBuildHistoryLimit = 5
+	// BuildSucceeded, BuildFailed and BuildRunning are the possible results of the builds recorded in the build history
+	BuildSucceeded = "Succeeded"
+	BuildFailed    = "Failed"
+	BuildRunning   = "Running"
+	// BuildCancelled and BuildTimedOut are the results of the failed builds which were cancelled or didn't complete in time
+	BuildCancelled = "Cancelled"
+	BuildTimedOut  = "TimedOut"
+	// BuildNumberAttributeKey, BuildAttemptAttributeKey, RefAttributeKey, CommitAttributeKey and BuildResultAttributeKey
+	// are the condition attributes recording the number, attempt, git ref, built commit and result of the current build
+	BuildNumberAttributeKey  = "BuildNumber"
+	BuildAttemptAttributeKey = "Attempt"
+	RefAttributeKey          = "Ref"
+	CommitAttributeKey       = "Commit"
+	BuildResultAttributeKey  = "Result"
+	// BuildHistoryAttributeKey is the condition attribute recording, as a JSON list of BuildRecord, the builds kept in the
+	// history of the component
+	BuildHistoryAttributeKey = "History"
+)
+
+// BuildRecord summarizes a build of the component in its build history
+type BuildRecord struct {
+	Name           string       `json:"name"`
+	Number         int          `json:"number"`
+	Attempt        int          `json:"attempt"`
+	Ref            string       `json:"ref"`
+	Commit         string       `json:"commit,omitempty"`
+	ImageDigest    string       `json:"imageDigest,omitempty"`
+	SourceDigest   string       `json:"sourceDigest,omitempty"`
+	StartTime      *metav1.Time `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	Result         string       `json:"result"`
+	// Failure describes, without its log, why the build failed
+	Failure *BuildFailure `json:"failure,omitempty"`
+}
+
+// buildFingerprint computes a fingerprint of what the component's build depends on: a new build is started whenever it
+// changes, which includes bumping the RebuildAnnotation. Where the image is pushed isn't part of it as it's not an input
+// of the build.
+func buildFingerprint(c *v1beta1.Component) string {
+	hash := sha256.New()
+	config := c.Spec.BuildConfig
+	rebuild, _ := getAnnotation(c, RebuildAnnotation)
+	args, _ := getAnnotation(c, BuildArgsAnnotation)
+	builder, _ := getAnnotation(c, BuildpacksBuilderAnnotation)
+	purge, _ := getAnnotation(c, PurgeBuildCacheAnnotation)
+	env, _ := getAnnotation(c, BuildEnvAnnotation)
+	logLevel, _ := getAnnotation(c, BuildLogLevelAnnotation)
+	archive, _ := getAnnotation(c, SourceArchiveAnnotation)
+	for _, value := range []string{config.Type, config.URL, gitRevision(c), archive, contextPath(c), moduleDirName(c), baseImage(c),
+		dockerfile(c), args, builder, env, logLevel, c.Spec.Runtime, c.Spec.Version, rebuild, purge} {
+		_, _ = fmt.Fprintf(hash, "%s\n", value)
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil))
+}
+
+// buildNumber returns the number of the build recorded with the BuildNumberAnnotation on the specified object, 0 if none
+func buildNumber(meta metav1.Object) int {
+	number, err := strconv.Atoi(meta.GetAnnotations()[BuildNumberAnnotation])
+	if err != nil {
+		return 0
+	}
+	return number
+}
+
+// buildName returns the name of the TaskRun building the current state of the component
+func buildName(c *v1beta1.Component) string {
+	return fmt.Sprintf("%s-build-%d", c.Name, buildNumber(c))
+}
+
+// assignBuildNumber records on the component the number of the build matching its current build fingerprint, assigning
+// the next build number if the fingerprint changed. Returns whether the component was modified and needs to be updated.
+// The number is persisted on the component, rather than computed from the existing TaskRuns, so that a stale view of the
+// TaskRuns cannot result in the same state being built twice.
+func assignBuildNumber(c *v1beta1.Component) bool {
+	fingerprint := buildFingerprint(c)
+	if recorded, _ := getAnnotation(c, BuildFingerprintAnnotation); recorded == fingerprint && buildNumber(c) > 0 {
+		return false
+	}
+
+	next := nextBuildNumber(c)
+	if c.Annotations == nil {
+		c.Annotations = make(map[string]string, 2)
+	}
+	c.Annotations[BuildFingerprintAnnotation] = fingerprint
+	c.Annotations[BuildNumberAnnotation] = strconv.Itoa(next)
+	// a new state to build starts with its first attempt
+	delete(c.Annotations, BuildAttemptAnnotation)
+	return true
+}
+
+// nextBuildNumber returns the number of the next build of the component, greater than the number of any of its builds
+func nextBuildNumber(c *v1beta1.Component) int {
+	next := buildNumber(c) + 1
+	if builds, err := listBuilds(c); err == nil {
+		for _, build := range builds {
+			if number := buildNumber(&build); number >= next {
+				next = number + 1
+			}
+		}
+	}
+	return next
+}
+
+// TriggerBuild requests a new build of the specified commit of the component's ref using the RebuildAnnotation, recording
+// the commit with the TriggeredCommitAnnotation so that the build checks it out even if the ref moved since. Returns
+// false if a build was already requested for this commit, in which case the component is left untouched.
+func TriggerBuild(c *v1beta1.Component, commit string) bool {
+	if rebuild, _ := getAnnotation(c, RebuildAnnotation); rebuild == commit {
+		return false
+	}
+	if c.Annotations == nil {
+		c.Annotations = make(map[string]string, 2)
+	}
+	c.Annotations[RebuildAnnotation] = commit
+	c.Annotations[TriggeredCommitAnnotation] = gitRevision(c) + "@" + commit
+	return true
+}
+
+// triggeredCommit returns the commit whose build was requested using TriggerBuild, empty if the component's ref changed
+// or a rebuild was requested since, the head of the ref being built then
+func triggeredCommit(c *v1beta1.Component) string {
+	triggered, _ := getAnnotation(c, TriggeredCommitAnnotation)
+	i := strings.LastIndex(triggered, "@")
+	if i < 0 {
+		return ""
+	}
+	ref, commit := triggered[:i], triggered[i+1:]
+	if rebuild, _ := getAnnotation(c, RebuildAnnotation); ref != gitRevision(c) || rebuild != commit || !git.IsCommitSHA(commit) {
+		return ""
+	}
+	return commit
+}
+
+// BuildRef returns the git ref built by the specified component
+func BuildRef(c *v1beta1.Component) string {
+	return gitRevision(c)
+}
+
+// listBuilds returns all the TaskRuns which built the component, most recent first
+func listBuilds(c *v1beta1.Component) ([]tekton.TaskRun, error) {
+	taskRuns := &tekton.TaskRunList{}
+	lo := &client.ListOptions{}
+	lo.InNamespace(c.Namespace)
+	lo.MatchingLabels(getBuildLabels(c.Name))
+	if err := framework.Helper.Client.List(context.TODO(), lo, taskRuns); err != nil {
+		return nil, err
+	}
+	builds := taskRuns.Items
+	sort.SliceStable(builds, func(i, j int) bool {
+		return buildNumber(&builds[i]) > buildNumber(&builds[j])
+	})
+	return builds, nil
+}
+
+// lastSuccessfulBuild returns the most recently completed successful build of the component, nil if there's none
+func lastSuccessfulBuild(c *v1beta1.Component) (*tekton.TaskRun, error) {
+	builds, err := listBuilds(c)
+	if err != nil {
+		return nil, err
+	}
+	return lastSuccessful(builds), nil
+}
+
+func lastSuccessful(builds []tekton.TaskRun) *tekton.TaskRun {
+	var last *tekton.TaskRun
+	for i, build := range builds {
+		if !isSuccessful(&build) || build.Status.CompletionTime == nil {
+			continue
+		}
+		if last == nil || last.Status.CompletionTime.Before(build.Status.CompletionTime) {
+			last = &builds[i]
+		}
+	}
+	return last
+}
+
+// deployedBuild returns the build whose image should be deployed: the build pinned using the DeployedBuildAnnotation if
+// any, the last successful build otherwise. Returns nil if there's no such build.
+func deployedBuild(c *v1beta1.Component) (*tekton.TaskRun, error) {
+	name, pinned := getAnnotation(c, DeployedBuildAnnotation)
+	if !pinned {
+		return lastSuccessfulBuild(c)
+	}
+	tr := &tekton.TaskRun{}
+	if _, err := framework.Helper.Fetch(name, c.Namespace, tr); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, fmt.Errorf("'%s' build to deploy for component '%s' doesn't exist", name, c.Name)
+		}
+		return nil, err
+	}
+	if tr.Labels["component_cr"] != c.Name {
+		return nil, fmt.Errorf("'%s' build to deploy didn't build component '%s'", name, c.Name)
+	}
+	if !isSuccessful(tr) {
+		return nil, fmt.Errorf("'%s' build to deploy for component '%s' didn't succeed", name, c.Name)
+	}
+	if len(buildImageDigest(tr)) == 0 {
+		return nil, fmt.Errorf("the digest of the image produced by the '%s' build is unknown, it cannot be deployed again", name)
+	}
+	return tr, nil
+}
+
+// buildImageDigest returns the digest of the image produced by the specified build, as recorded by the operator or by Tekton
+func buildImageDigest(tr *tekton.TaskRun) string {
+	if digest := tr.Annotations[ImageDigestAnnotation]; len(digest) > 0 {
+		return digest
+	}
+	return imageDigest(tr)
+}
+
+// buildHistoryLimit returns how many builds of the component are kept
+func buildHistoryLimit(c *v1beta1.Component) (int, error) {
+	value, found := getAnnotation(c, BuildHistoryLimitAnnotation)
+	if !found {
+		return defaultBuildHistoryLimit, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 {
+		return 0, fmt.Errorf("invalid '%s' annotation on component '%s': must be a positive number", BuildHistoryLimitAnnotation, c.Name)
+	}
+	return limit, nil
+}
+
+// updateBuildHistory records the digest of the images produced by the builds of the component, prunes the builds beyond
+// the history limit along with the source archives uploaded for them and records the remaining ones on the component using the BuildHistoryAnnotation. Returns whether
+// the component was modified and needs to be updated. The history is also reported by the condition of the current
+// build, see setBuildAttributes, which doesn't depend on the component being successfully updated.
+func updateBuildHistory(c *v1beta1.Component) (bool, error) {
+	limit, err := buildHistoryLimit(c)
+	if err != nil {
+		return false, err
+	}
+	builds, err := listBuilds(c)
+	if err != nil {
+		return false, err
+	}
+
+	if err := recordImageDigests(c, builds); err != nil {
+		return false, err
+	}
+	if err := recordBuildFailures(c, builds); err != nil {
+		return false, err
+	}
+
+	kept, pruned := keptBuilds(c, builds, limit)
+	for i := range pruned {
+		if err := framework.Helper.Client.Delete(context.TODO(), &pruned[i]); err != nil && !errors.IsNotFound(err) {
+			return false, err
+		}
+	}
+	if err := pruneSourceUploads(c, kept); err != nil {
+		return false, err
+	}
+
+	value, err := json.Marshal(buildRecords(kept))
+	if err != nil {
+		return false, err
+	}
+	if recorded, _ := getAnnotation(c, BuildHistoryAnnotation); recorded == string(value) {
+		return false, nil
+	}
+	if c.Annotations == nil {
+		c.Annotations = make(map[string]string, 1)
+	}
+	c.Annotations[BuildHistoryAnnotation] = string(value)
+	return true, nil
+}
+
+// keptBuilds splits the specified builds of the component, most recent first, into the ones kept in its history and the
+// ones to prune: the most recent ones up to the specified limit are kept, along with the current and deployed builds
+func keptBuilds(c *v1beta1.Component, builds []tekton.TaskRun, limit int) (kept []tekton.TaskRun, pruned []tekton.TaskRun) {
+	kept = make([]tekton.TaskRun, 0, limit)
+	deployed, _ := getAnnotation(c, DeployedBuildAnnotation)
+	for i, build := range builds {
+		if i < limit || build.Name == buildName(c) || build.Name == deployed {
+			kept = append(kept, build)
+		} else {
+			pruned = append(pruned, build)
+		}
+	}
+	return kept, pruned
+}
+
+// buildRecords summarizes the specified builds
+func buildRecords(builds []tekton.TaskRun) []BuildRecord {
+	records := make([]BuildRecord, 0, len(builds))
+	for i := range builds {
+		records = append(records, newBuildRecord(&builds[i]))
+	}
+	return records
+}
+
+// recordImageDigests records on the successful builds the digest of the image they produced so that it can be deployed
+// again once the image tag has moved to a newer build. If Tekton didn't record it, the digest can only be resolved from
+// the registry for the last successful build: the tag has been overwritten for older builds.
+func recordImageDigests(c *v1beta1.Component, builds []tekton.TaskRun) error {
+	last := lastSuccessful(builds)
+	for i, build := range builds {
+		if !isSuccessful(&build) || len(build.Annotations[ImageDigestAnnotation]) > 0 {
+			continue
+		}
+		digest := imageDigest(&build)
+		if len(digest) == 0 && last != nil && last.Name == build.Name {
+			image, err := builtImageName(c, &build)
+			if err != nil {
+				return err
+			}
+			verify, err := registryTLS(c)
+			if err != nil {
+				return err
+			}
+			if resolved, err := imageResolver.DigestSince(image, registryKeychain(c.Namespace, imagePullSecrets(c)), verify, build.Status.CompletionTime.Time); err == nil {
+				digest = resolved
+			}
+		}
+		if len(digest) == 0 {
+			continue
+		}
+		if builds[i].Annotations == nil {
+			builds[i].Annotations = make(map[string]string, 1)
+		}
+		builds[i].Annotations[ImageDigestAnnotation] = digest
+		if err := framework.Helper.Client.Update(context.TODO(), &builds[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newBuildRecord(tr *tekton.TaskRun) BuildRecord {
+	record := BuildRecord{
+		Name:           tr.Name,
+		Number:         buildNumber(tr),
+		Attempt:        buildAttempt(tr),
+		Ref:            buildRevision(tr),
+		Commit:         buildCommit(tr),
+		ImageDigest:    buildImageDigest(tr),
+		SourceDigest:   sourceDigest(tr),
+		StartTime:      tr.Status.StartTime,
+		CompletionTime: tr.Status.CompletionTime,
+		Result:         BuildRunning,
+	}
+	if failure := buildFailure(tr); failure != nil && len(failure.Step) > 0 {
+		failure.Log = ""
+		record.Failure = failure
+	}
+	if succeeded := tr.Status.GetCondition(apis.ConditionSucceeded); succeeded != nil {
+		if succeeded.IsTrue() {
+			record.Result = BuildSucceeded
+		} else if isCancelled(tr) {
+			record.Result = BuildCancelled
+		} else if isTimedOut(tr) {
+			record.Result = BuildTimedOut
+		} else if succeeded.IsFalse() {
+			record.Result = BuildFailed
+		}
+	}
+	return record
+}
+
+// buildRevision returns the git revision the specified build checked out
+func buildRevision(tr *tekton.TaskRun) string {
+	for _, param := range tr.Spec.Params {
+		if param.Name == "revision" {
+			return param.Value.StringVal
+		}
+	}
+	return ""
+}
+
+// buildCommit returns the SHA of the commit the specified build checked out, if known: either reported by the build,
+// recorded on the TaskRun using the CommitAnnotation or because the build's revision is a commit SHA
+func buildCommit(tr *tekton.TaskRun) string {
+	if commit, found := tr.Status.Result(commitResult); found && len(commit) > 0 {
+		return commit
+	}
+	if commit := tr.Annotations[CommitAnnotation]; len(commit) > 0 {
+		return commit
+	}
+	if revision := buildRevision(tr); git.IsCommitSHA(revision) {
+		return revision
+	}
+	return ""
+}
+
+// builtImageName returns the reference of the image the specified build pushed, which might differ from the component's
+// current one if the registry configuration changed since the build
+func builtImageName(c *v1beta1.Component, tr *tekton.TaskRun) (string, error) {
+	for _, param := range tr.Spec.Params {
+		if param.Name == "image" && len(param.Value.StringVal) > 0 {
+			return param.Value.StringVal, nil
+		}
+	}
+	return dockerImageURL(c)
+}
+
+// builtImageFor returns the image produced by the specified build, pinned to its digest if known
+func builtImageFor(c *v1beta1.Component, tr *tekton.TaskRun) (string, bool, error) {
+	image, err := builtImageName(c, tr)
+	if err != nil {
+		return "", false, err
+	}
+	digest := buildImageDigest(tr)
+	if len(digest) == 0 {
+		return image, false, nil
+	}
+	ref, err := registry.ParseReference(image)
+	if err != nil {
+		return image, false, nil
+	}
+	return ref.WithDigest(digest), true, nil
+}
@@ -0,0 +1,121 @@
+package component
+
+import (
+	"fmt"
+	"halkyon.io/api/component/v1beta1"
+	"halkyon.io/operator/pkg/tekton"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// newTestBuild returns the build of the backend test component with the specified number
+func newTestBuild(number int) tekton.TaskRun {
+	return tekton.TaskRun{ObjectMeta: metav1.ObjectMeta{
+		Name:        fmt.Sprintf("backend-build-%d", number),
+		Annotations: map[string]string{BuildNumberAnnotation: strconv.Itoa(number)},
+	}}
+}
+
+func buildNames(builds []tekton.TaskRun) []string {
+	names := make([]string, 0, len(builds))
+	for _, build := range builds {
+		names = append(names, build.Name)
+	}
+	return names
+}
+
+func TestBuildFingerprint(t *testing.T) {
+	reference := buildFingerprint(newTestComponent(nil))
+	tests := []struct {
+		name    string
+		modify  func(c *v1beta1.Component)
+		changes bool
+	}{
+		{name: "unchanged", modify: func(c *v1beta1.Component) {}},
+		{name: "url", modify: func(c *v1beta1.Component) { c.Spec.BuildConfig.URL = "https://github.com/halkyonio/other.git" }, changes: true},
+		{name: "ref", modify: func(c *v1beta1.Component) { c.Spec.BuildConfig.Ref = "develop" }, changes: true},
+		{name: "default ref", modify: func(c *v1beta1.Component) { c.Spec.BuildConfig.Ref = "" }},
+		{name: "context path", modify: func(c *v1beta1.Component) { c.Spec.BuildConfig.ContextPath = "backend" }, changes: true},
+		{name: "runtime version", modify: func(c *v1beta1.Component) { c.Spec.Version = "2.2.0.RELEASE" }, changes: true},
+		{name: "rebuild", modify: func(c *v1beta1.Component) { c.Annotations = map[string]string{RebuildAnnotation: "1"} }, changes: true},
+		{name: "purge cache", modify: func(c *v1beta1.Component) { c.Annotations = map[string]string{PurgeBuildCacheAnnotation: "1"} }, changes: true},
+		{name: "build env", modify: func(c *v1beta1.Component) { c.Annotations = map[string]string{BuildEnvAnnotation: `{"A": "b"}`} }, changes: true},
+		{name: "source archive", modify: func(c *v1beta1.Component) {
+			c.Annotations = map[string]string{SourceArchiveAnnotation: `{"configMap": "backend-source-0123456789ab", "path": "source"}`}
+		}, changes: true},
+		// neither the deployment, the history nor the destination of the image are inputs of the build
+		{name: "port", modify: func(c *v1beta1.Component) { c.Spec.Port = 9090 }},
+		{name: "build history", modify: func(c *v1beta1.Component) { c.Annotations = map[string]string{BuildHistoryLimitAnnotation: "3"} }},
+		{name: "deployed tag", modify: func(c *v1beta1.Component) { c.Annotations = map[string]string{DeployedTagAnnotation: "prod"} }},
+		{name: "empty annotation", modify: func(c *v1beta1.Component) { c.Annotations = map[string]string{RebuildAnnotation: ""} }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestComponent(nil)
+			test.modify(c)
+			if changed := buildFingerprint(c) != reference; changed != test.changes {
+				t.Errorf("expected fingerprint change to be %t, got %t", test.changes, changed)
+			}
+		})
+	}
+}
+
+func TestKeptBuilds(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		builds      int
+		limit       int
+		kept        []string
+		pruned      []string
+	}{
+		{
+			name:        "below the limit",
+			annotations: map[string]string{BuildNumberAnnotation: "3"},
+			builds:      3,
+			limit:       5,
+			kept:        []string{"backend-build-3", "backend-build-2", "backend-build-1"},
+		},
+		{
+			name:        "beyond the limit",
+			annotations: map[string]string{BuildNumberAnnotation: "5"},
+			builds:      5,
+			limit:       2,
+			kept:        []string{"backend-build-5", "backend-build-4"},
+			pruned:      []string{"backend-build-3", "backend-build-2", "backend-build-1"},
+		},
+		{
+			name:        "deployed build",
+			annotations: map[string]string{BuildNumberAnnotation: "5", DeployedBuildAnnotation: "backend-build-2"},
+			builds:      5,
+			limit:       2,
+			kept:        []string{"backend-build-5", "backend-build-4", "backend-build-2"},
+			pruned:      []string{"backend-build-3", "backend-build-1"},
+		},
+		{
+			name:        "current build",
+			annotations: map[string]string{BuildNumberAnnotation: "2"},
+			builds:      4,
+			limit:       1,
+			kept:        []string{"backend-build-4", "backend-build-2"},
+			pruned:      []string{"backend-build-3", "backend-build-1"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			builds := make([]tekton.TaskRun, 0, test.builds)
+			for number := test.builds; number > 0; number-- {
+				builds = append(builds, newTestBuild(number))
+			}
+			kept, pruned := keptBuilds(newTestComponent(test.annotations), builds, test.limit)
+			if names := buildNames(kept); !reflect.DeepEqual(names, test.kept) {
+				t.Errorf("expected %v builds to be kept, got %v", test.kept, names)
+			}
+			if names := buildNames(pruned); !reflect.DeepEqual(names, append([]string{}, test.pruned...)) {
+				t.Errorf("expected %v builds to be pruned, got %v", test.pruned, names)
+			}
+		})
+	}
+}
@@ -47,7 +47,7 @@ func (in *Component) InitDependentResources() ([]framework.DependentResource, er
 	c := in.Component
 	dependents := make([]framework.DependentResource, 0, 20)
 	dependents = append(dependents, in.BaseResource.AddDependentResource(newRole(in), framework.NewOwnedRoleBinding(in), newServiceAccount(c), newPvc(c),
-		newDeployment(c), newService(c), newRoute(c), newIngress(c), newTask(c), newTaskRun(c), newPod(c))...)
+		newBuildCachePvc(c), newRegistryCA(c), newDeployment(c), newService(c), newRoute(c), newIngress(c), newTask(c), newTaskRun(c), newPod(c))...)
 
 	requiredCapabilities := c.Spec.Capabilities.Requires
 	for _, config := range requiredCapabilities {
@@ -63,6 +63,7 @@ func (in *Component) InitDependentResources() ([]framework.DependentResource, er
 }
 
 func (in *Component) Delete() error {
+	forgetPolls(in.Component)
 	if framework.IsTargetClusterRunningOpenShift() {
 		// Delete the ImageStream created by OpenShift if it exists as the Component doesn't own this resource
 		// when it is created during build deployment mode
@@ -87,6 +88,16 @@ func (in *Component) Delete() error {
 
 func (in *Component) CreateOrUpdate() (err error) {
 	if halkyon.BuildDeploymentMode == in.Spec.DeploymentMode {
+		// the build cache shared by the namespace isn't owned by the component, it's therefore not one of its dependents
+		if err = ensureSharedBuildCache(in.Component); err != nil {
+			return err
+		}
+		if err = prepareGitCredentials(in.Component); err != nil {
+			return err
+		}
+		if err = cleanupLegacyBuildResources(in.Namespace); err != nil {
+			return err
+		}
 		err = in.CreateOrUpdateDependents()
 	} else {
 		// Enrich Component with k8s recommend Labels
@@ -104,14 +115,48 @@ func (in *Component) CreateOrUpdate() (err error) {
 	if err != nil {
 		return err
 	}
+	if err = applyPodTemplateExtensions(in.Component); err != nil {
+		return err
+	}
 
 	// link to the capabilities if they're ready and we've bound them to a capability already
 	needsSpecUpdate := false
 	defer func() {
 		if needsSpecUpdate {
-			_ = framework.Helper.Client.Update(context.Background(), in.Component)
+			if e := framework.Helper.Client.Update(context.Background(), in.Component); e != nil {
+				// the component might have been modified by another process: what was recorded on it, e.g. its build
+				// history, is recorded again once requeued
+				in.SetNeedsRequeue(true)
+				if err == nil {
+					err = e
+				}
+			}
 		}
 	}()
+	if halkyon.BuildDeploymentMode == in.Spec.DeploymentMode {
+		if err = cancelBuild(in.Component); err != nil {
+			return err
+		}
+		// record the builds of the component and prune the old ones
+		if needsSpecUpdate, err = updateBuildHistory(in.Component); err != nil {
+			return err
+		}
+		// retry the current build if it failed because of the infrastructure
+		var retried, pending bool
+		if retried, pending, err = retryBuild(in.Component); err != nil {
+			return err
+		}
+		if pending {
+			in.SetNeedsRequeue(true)
+		}
+		needsSpecUpdate = retried || needsSpecUpdate
+		// retag the image to promote, if any, and deploy it
+		var promoted bool
+		if promoted, err = promoteImage(in.Component); err != nil {
+			return err
+		}
+		needsSpecUpdate = promoted || needsSpecUpdate
+	}
 	for i, required := range in.Spec.Capabilities.Requires {
 		if dependentCap, err := in.GetDependent(predicateFor(required.CapabilityConfig)); err == nil {
 			// attempt to retrieve the associated capability, this will bind the capability if set to auto-bindable
@@ -157,7 +202,7 @@ func (in *Component) updateComponentWithLinkInfo(c halkyon.RequiredCapabilityCon
 	}
 	deployment := d.(*appsv1.Deployment)
 	containers := deployment.Spec.Template.Spec.Containers
-	secretName := fmt.Sprintf("%s-config", c.BoundTo) // todo: we need to retrieve the secret name from the capability
+	secretName := capabilitySecretName(c)
 
 	// Check if EnvFrom already exists
 	// If this is the case, exit without error
@@ -165,7 +210,7 @@ func (in *Component) updateComponentWithLinkInfo(c halkyon.RequiredCapabilityCon
 	for i := 0; i < len(containers); i++ {
 		var isEnvFromExist = false
 		for _, env := range containers[i].EnvFrom {
-			if env.SecretRef.Name == secretName {
+			if env.SecretRef != nil && env.SecretRef.Name == secretName {
 				// EnvFrom already exists for the Secret Ref
 				isEnvFromExist = true
 			}
@@ -185,6 +230,10 @@ func (in *Component) updateComponentWithLinkInfo(c halkyon.RequiredCapabilityCon
 	return
 }
 
+func capabilitySecretName(c halkyon.RequiredCapabilityConfig) string {
+	return fmt.Sprintf("%s-config", c.BoundTo) // todo: we need to retrieve the secret name from the capability
+}
+
 func addSecretAsEnvFromSource(secretName string) corev1.EnvFromSource {
 	return corev1.EnvFromSource{
 		SecretRef: &corev1.SecretEnvSource{
@@ -220,6 +269,9 @@ func (in *Component) ProvideDefaultValues() bool {
 		return true
 	}
 	in.Spec.Storage.Name = PVCName(in.Component)
+	if halkyon.BuildDeploymentMode == in.Spec.DeploymentMode {
+		return assignBuildNumber(in.Component)
+	}
 	return false
 }
 
@@ -232,6 +284,86 @@ func (in *Component) CheckValidity() error {
 	if in.Spec.Port == 0 {
 		return fmt.Errorf("component '%s' must provide a port", in.Name)
 	}
+	if _, found := getAnnotation(in.Component, ImageAnnotation); isImageDeploymentMode(in.Component) && !found {
+		return fmt.Errorf("component '%s' must provide the image to deploy using the '%s' annotation", in.Name, ImageAnnotation)
+	}
+	if _, err := getEnvValueSources(in.Component); err != nil {
+		return err
+	}
+	if _, err := getEnvFromSources(in.Component); err != nil {
+		return err
+	}
+	if _, _, err := getUserVolumes(in.Component); err != nil {
+		return err
+	}
+	if _, _, err := getUserContainers(in.Component); err != nil {
+		return err
+	}
+	if _, err := getScheduling(in.Component, SchedulingAnnotation); err != nil {
+		return err
+	}
+	if _, err := getScheduling(in.Component, BuildSchedulingAnnotation); err != nil {
+		return err
+	}
+	if _, err := podSecurity(in.Component); err != nil {
+		return err
+	}
+	if _, err := imagePullPolicy(in.Component, false); err != nil {
+		return err
+	}
+	if halkyon.BuildDeploymentMode == in.Spec.DeploymentMode {
+		if _, err := buildType(in.Component); err != nil {
+			return err
+		}
+		archive, err := sourceArchive(in.Component)
+		if err != nil {
+			return err
+		}
+		if archive == nil && len(in.Spec.BuildConfig.URL) == 0 {
+			return fmt.Errorf("component '%s' must provide the url of its git repository or a source archive using the '%s' annotation", in.Name, SourceArchiveAnnotation)
+		}
+		if err := checkRegistryConfig(in.Component); err != nil {
+			return err
+		}
+		if _, err := buildArgs(in.Component); err != nil {
+			return err
+		}
+		if _, err := componentBuildEnv(in.Component); err != nil {
+			return err
+		}
+		if _, err := s2iLogLevel(in.Component); err != nil {
+			return err
+		}
+		if _, err := buildCache(in.Component); err != nil {
+			return err
+		}
+		if _, err := buildCacheSize(in.Component); err != nil {
+			return err
+		}
+		if _, err := buildTimeout(in.Component); err != nil {
+			return err
+		}
+		if _, err := buildRetries(in.Component); err != nil {
+			return err
+		}
+		if _, err := buildHistoryLimit(in.Component); err != nil {
+			return err
+		}
+		if _, _, err := pollInterval(in.Component); err != nil {
+			return err
+		}
+		if _, _, err := deployedTag(in.Component); err != nil {
+			return err
+		}
+		if _, err := requestedPromotion(in.Component); err != nil {
+			return err
+		}
+		if _, pinned := getAnnotation(in.Component, DeployedBuildAnnotation); pinned {
+			if _, err := deployedBuild(in.Component); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
@@ -240,11 +372,11 @@ func (in *Component) Owner() framework.SerializableResource {
 }
 
 func (in *Component) GetRoleName() string {
-	return "image-scc-privileged-role"
+	return in.Name + "-image-scc-privileged-role"
 }
 
 func (in *Component) GetRoleBindingName() string {
-	return "use-image-scc-privileged"
+	return in.Name + "-use-image-scc-privileged"
 }
 
 func (in *Component) GetAssociatedRoleName() string {
@@ -0,0 +1,50 @@
+package component
+
+import (
+	"fmt"
+	component "halkyon.io/api/component/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// getUserContainers returns the sidecar and init containers the component declares using the ContainersAnnotation and
+// InitContainersAnnotation, checking that they can be added to the pod the operator generates
+func getUserContainers(c *component.Component) (containers []corev1.Container, initContainers []corev1.Container, err error) {
+	if _, err = decodeAnnotation(c, ContainersAnnotation, &containers); err != nil {
+		return nil, nil, err
+	}
+	if _, err = decodeAnnotation(c, InitContainersAnnotation, &initContainers); err != nil {
+		return nil, nil, err
+	}
+
+	volumes, _, err := getUserVolumes(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	volumeNames := make(map[string]bool, len(volumes)+2)
+	if isDevDeploymentMode(c) {
+		// dev mode pods also provide the supervisord and storage volumes
+		volumeNames[sharedDataVolumeName] = true
+		volumeNames[PVCName(c)] = true
+	}
+	for _, volume := range volumes {
+		volumeNames[volume.Name] = true
+	}
+
+	names := map[string]bool{c.Name: true, supervisorContainerName: true}
+	for _, container := range append(append([]corev1.Container{}, containers...), initContainers...) {
+		if len(container.Name) == 0 || len(container.Image) == 0 {
+			return nil, nil, fmt.Errorf("additional containers must provide both a name and an image")
+		}
+		if names[container.Name] {
+			return nil, nil, fmt.Errorf("'%s' container name is already used", container.Name)
+		}
+		names[container.Name] = true
+		for _, mount := range container.VolumeMounts {
+			if !volumeNames[mount.Name] {
+				return nil, nil, fmt.Errorf("'%s' container mounts unknown '%s' volume", container.Name, mount.Name)
+			}
+		}
+	}
+
+	return containers, initContainers, nil
+}
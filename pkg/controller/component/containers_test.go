@@ -0,0 +1,60 @@
+package component
+
+import (
+	"halkyon.io/api/component/v1beta1"
+	"testing"
+)
+
+func TestGetUserContainers(t *testing.T) {
+	c := newTestComponent(map[string]string{
+		VolumesAnnotation:        `[{"name": "config", "configMap": {"name": "backend-config"}}]`,
+		ContainersAnnotation:     `[{"name": "proxy", "image": "envoyproxy/envoy:v1.14.1", "volumeMounts": [{"name": "config", "mountPath": "/etc/envoy"}]}]`,
+		InitContainersAnnotation: `[{"name": "migrate", "image": "flyway/flyway:6.4"}]`,
+	})
+	containers, initContainers, err := getUserContainers(c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(containers) != 1 || containers[0].Name != "proxy" || len(containers[0].VolumeMounts) != 1 {
+		t.Errorf("unexpected containers: %+v", containers)
+	}
+	if len(initContainers) != 1 || initContainers[0].Name != "migrate" {
+		t.Errorf("unexpected init containers: %+v", initContainers)
+	}
+
+	// dev mode pods provide the supervisord and storage volumes
+	c = newTestComponent(map[string]string{ContainersAnnotation: `[{"name": "sync", "image": "busybox", "volumeMounts": [{"name": "` + sharedDataVolumeName + `", "mountPath": "/shared"}]}]`})
+	c.Spec.DeploymentMode = v1beta1.DevDeploymentMode
+	if containers, _, err := getUserContainers(c); err != nil || len(containers) != 1 {
+		t.Errorf("expected dev mode containers to mount the shared data volume, got %+v: %v", containers, err)
+	}
+
+	if containers, initContainers, err := getUserContainers(newTestComponent(nil)); err != nil || len(containers) != 0 || len(initContainers) != 0 {
+		t.Errorf("expected no containers by default, got %+v and %+v: %v", containers, initContainers, err)
+	}
+}
+
+func TestGetInvalidUserContainers(t *testing.T) {
+	tests := []struct {
+		name           string
+		containers     string
+		initContainers string
+	}{
+		{name: "missing name", containers: `[{"image": "busybox"}]`},
+		{name: "missing image", initContainers: `[{"name": "migrate"}]`},
+		{name: "component name", containers: `[{"name": "backend", "image": "busybox"}]`},
+		{name: "supervisor name", initContainers: `[{"name": "` + supervisorContainerName + `", "image": "busybox"}]`},
+		{name: "duplicate name", containers: `[{"name": "proxy", "image": "busybox"}]`, initContainers: `[{"name": "proxy", "image": "busybox"}]`},
+		{name: "unknown volume", containers: `[{"name": "proxy", "image": "busybox", "volumeMounts": [{"name": "config", "mountPath": "/config"}]}]`},
+		{name: "shared data volume in build mode", containers: `[{"name": "sync", "image": "busybox", "volumeMounts": [{"name": "` + sharedDataVolumeName + `", "mountPath": "/shared"}]}]`},
+		{name: "unknown field", containers: `[{"name": "proxy", "image": "busybox", "volumes": []}]`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestComponent(map[string]string{ContainersAnnotation: test.containers, InitContainersAnnotation: test.initContainers})
+			if containers, initContainers, err := getUserContainers(c); err == nil {
+				t.Errorf("expected the containers to be rejected, got %+v and %+v", containers, initContainers)
+			}
+		})
+	}
+}
@@ -5,9 +5,9 @@ import (
 	"halkyon.io/api/v1beta1"
 	"halkyon.io/operator-framework"
 	appsv1 "k8s.io/api/apps/v1"
-	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"reflect"
+	"strings"
 )
 
 type deployment struct {
@@ -31,6 +31,9 @@ func (res deployment) Build(empty bool) (runtime.Object, error) {
 	if component.BuildDeploymentMode == c.Spec.DeploymentMode {
 		return res.installBuild(empty)
 	}
+	if isImageDeploymentMode(c) {
+		return res.installImage(empty)
+	}
 	return res.installDev(empty)
 }
 
@@ -41,14 +44,26 @@ func (res deployment) Name() string {
 func (res deployment) GetCondition(underlying runtime.Object, err error) *v1beta1.DependentCondition {
 	return framework.DefaultCustomizedGetConditionFor(res, err, underlying, func(underlying runtime.Object, cond *v1beta1.DependentCondition) {
 		c := res.ownerAsComponent()
-		if _, e := getImageInfo(c); e != nil {
+		if _, e := getImageInfo(c); e != nil && needsRuntime(c) {
 			cond.Type = v1beta1.DependentFailed
 			cond.Reason = "UnavailableRuntime"
 			cond.Message = e.Error()
+			return
 		}
 		cond.Type = v1beta1.DependentReady
 		cond.Reason = string(v1beta1.DependentReady)
 		cond.Message = ""
+
+		// record which image is deployed and, if it was built, by which build
+		template := underlying.(*appsv1.Deployment).Spec.Template
+		image := template.Spec.Containers[0].Image
+		cond.SetAttribute(ImageAttributeKey, image)
+		if i := strings.IndexRune(image, '@'); i > 0 {
+			cond.SetAttribute(ImageDigestAttributeKey, image[i+1:])
+		}
+		if build, found := template.Annotations[BuildAnnotation]; found {
+			cond.SetAttribute(BuildAttributeKey, build)
+		}
 	})
 }
 
@@ -56,37 +71,75 @@ func (res deployment) Update(toUpdate runtime.Object) (bool, runtime.Object, err
 	deployment := toUpdate.(*appsv1.Deployment)
 	container := deployment.Spec.Template.Spec.Containers[0]
 	c := res.ownerAsComponent()
-	envAsMap, err := getEnvAsMap(c)
-	if err != nil {
-		return false, nil, err
-	}
-	existingAsMap := make(map[string]string, len(container.Env))
-	for _, envVar := range container.Env {
-		existingAsMap[envVar.Name] = envVar.Value
-	}
-	if !reflect.DeepEqual(envAsMap, existingAsMap) {
-		newEnvVars := make([]corev1.EnvVar, 0, len(envAsMap))
-		for k, v := range envAsMap {
-			newEnvVars = append(newEnvVars, corev1.EnvVar{Name: k, Value: v})
+
+	// if the annotations shaping the pod template changed, re-generate it
+	if hash := podConfigHash(c); deployment.Spec.Template.Annotations[PodConfigHashAnnotation] != hash {
+		desired, err := res.Build(false)
+		if err != nil {
+			return false, nil, err
 		}
-		container.Env = newEnvVars
-		deployment.Spec.Template.Spec.Containers[0] = container
+		template := desired.(*appsv1.Deployment).Spec.Template
+		// keep the secrets injected when linking to capabilities
+		envFrom, err := populatePodEnvFrom(c, container.EnvFrom)
+		if err != nil {
+			return false, nil, err
+		}
+		template.Spec.Containers[0].EnvFrom = envFrom
+		deployment.Spec.Template = template
 		return true, deployment, nil
 	}
-	return false, deployment, nil
-}
 
-func populatePodEnvVar(component *component.Component) []corev1.EnvVar {
-	tmpEnvVar, err := getEnvAsMap(component)
+	updated := false
+
+	// roll the image produced by a new build out
+	if component.BuildDeploymentMode == c.Spec.DeploymentMode {
+		image, pinned, build, err := builtImage(c)
+		if err != nil {
+			return false, nil, err
+		}
+		template := &deployment.Spec.Template
+		// only pin the image of the current build if it couldn't be resolved before so that the deployment doesn't drift
+		rollout := len(build) > 0 && (template.Annotations[BuildAnnotation] != build || (pinned && !strings.Contains(container.Image, "@")))
+		// the image of a deployed tag, which might not have been built by the component, is rolled out when the tag moves
+		if _, tagged, _ := deployedTag(c); tagged && container.Image != image {
+			pin, found := getAnnotation(c, PinImagesAnnotation)
+			rollout = rollout || pinned || (found && pin == "false")
+		}
+		if rollout {
+			pullPolicy, err := imagePullPolicy(c, pinned)
+			if err != nil {
+				return false, nil, err
+			}
+			container.Image = image
+			container.ImagePullPolicy = pullPolicy
+			if template.Annotations == nil {
+				template.Annotations = make(map[string]string, 1)
+			}
+			template.Annotations[BuildAnnotation] = build
+			updated = true
+		}
+	}
+
+	env, err := populatePodEnvVar(c)
 	if err != nil {
-		panic(err)
+		return false, nil, err
+	}
+	if !reflect.DeepEqual(env, sortedEnv(container.Env)) {
+		container.Env = env
+		updated = true
 	}
 
-	// Convert Map to Slice
-	newEnvVars := make([]corev1.EnvVar, 0, len(tmpEnvVar))
-	for k, v := range tmpEnvVar {
-		newEnvVars = append(newEnvVars, corev1.EnvVar{Name: k, Value: v})
+	envFrom, err := populatePodEnvFrom(c, container.EnvFrom)
+	if err != nil {
+		return false, nil, err
+	}
+	if !reflect.DeepEqual(envFrom, container.EnvFrom) && !(len(envFrom) == 0 && len(container.EnvFrom) == 0) {
+		container.EnvFrom = envFrom
+		updated = true
 	}
 
-	return newEnvVars
+	if updated {
+		deployment.Spec.Template.Spec.Containers[0] = container
+	}
+	return updated, deployment, nil
 }
@@ -2,6 +2,7 @@ package component
 
 import (
 	component "halkyon.io/api/component/v1beta1"
+	"halkyon.io/operator/pkg/registry"
 	"k8s.io/api/apps/v1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -22,6 +23,10 @@ func (res deployment) installDev(empty bool) (runtime.Object, error) {
 		if err != nil {
 			return nil, err
 		}
+		runtimeInfo, err := getImageInfo(c)
+		if err != nil {
+			return nil, err
+		}
 		runtimeContainer.Args = []string{
 			"-c",
 			"/var/lib/supervisord/conf/supervisor.conf",
@@ -32,9 +37,24 @@ func (res deployment) installDev(empty bool) (runtime.Object, error) {
 			Name:          "http",
 			Protocol:      "TCP",
 		}}
-		runtimeContainer.VolumeMounts = append(runtimeContainer.VolumeMounts, corev1.VolumeMount{Name: c.Spec.Storage.Name, MountPath: "/deployments"})
-		runtimeContainer.VolumeMounts = append(runtimeContainer.VolumeMounts, corev1.VolumeMount{Name: c.Spec.Storage.Name, MountPath: "/usr/src"})
-		runtimeContainer.VolumeMounts = append(runtimeContainer.VolumeMounts, corev1.VolumeMount{Name: c.Spec.Storage.Name, MountPath: "/tmp/artefacts"})
+		for _, path := range storageMountPaths {
+			runtimeContainer.VolumeMounts = append(runtimeContainer.VolumeMounts, corev1.VolumeMount{Name: c.Spec.Storage.Name, MountPath: path})
+		}
+
+		// add the volumes requested by the user
+		userVolumes, userMounts, err := getUserVolumes(c)
+		if err != nil {
+			return nil, err
+		}
+		runtimeContainer.VolumeMounts = append(runtimeContainer.VolumeMounts, userMounts...)
+		sidecars, initContainers, err := getUserContainers(c)
+		if err != nil {
+			return nil, err
+		}
+		scheduling, err := getScheduling(c, SchedulingAnnotation)
+		if err != nil {
+			return nil, err
+		}
 
 		// create the supervisor init container
 		supervisorContainer, err := getBaseContainerFor(getSupervisor())
@@ -58,20 +78,26 @@ func (res deployment) installDev(empty bool) (runtime.Object, error) {
 			},
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: ls,
-					Name:   c.Name,
+					Labels:      ls,
+					Name:        c.Name,
+					Annotations: podTemplateAnnotations(c),
 				},
 				Spec: corev1.PodSpec{
-					Containers:     []corev1.Container{runtimeContainer},
-					InitContainers: []corev1.Container{supervisorContainer},
-					Volumes: []corev1.Volume{
-						{Name: "shared-data",
+					Containers:       append([]corev1.Container{runtimeContainer}, sidecars...),
+					InitContainers:   append([]corev1.Container{supervisorContainer}, initContainers...),
+					ImagePullSecrets: imagePullSecrets(c, runtimeInfo.pullSecrets...),
+					Volumes: append([]corev1.Volume{
+						{Name: sharedDataVolumeName,
 							VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
 						{Name: c.Spec.Storage.Name,
 							VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: c.Spec.Storage.Name}}},
-					},
+					}, userVolumes...),
 				}},
 		}
+		scheduling.applyTo(&dep.Spec.Template.Spec)
+		if err := applyPodSecurity(c, &dep.Spec.Template); err != nil {
+			return nil, err
+		}
 	}
 
 	// Set Component instance as the owner and controller
@@ -84,13 +110,29 @@ func getBaseContainerFor(component *component.Component) (corev1.Container, erro
 		return corev1.Container{}, err
 	}
 
+	env, err := populatePodEnvVar(component)
+	if err != nil {
+		return corev1.Container{}, err
+	}
+	envFrom, err := populatePodEnvFrom(component, nil)
+	if err != nil {
+		return corev1.Container{}, err
+	}
+
+	image, pinned := pinImage(component, runtimeImage.RegistryRef, imagePullSecrets(component, runtimeImage.pullSecrets...), registry.TLS{})
+	pullPolicy, err := imagePullPolicy(component, pinned)
+	if err != nil {
+		return corev1.Container{}, err
+	}
+
 	container := corev1.Container{
-		Env:             populatePodEnvVar(component),
-		Image:           runtimeImage.RegistryRef,
-		ImagePullPolicy: corev1.PullAlways,
+		Env:             env,
+		EnvFrom:         envFrom,
+		Image:           image,
+		ImagePullPolicy: pullPolicy,
 		Name:            component.Name,
 		VolumeMounts: []corev1.VolumeMount{
-			{Name: "shared-data", MountPath: "/var/lib/supervisord"},
+			{Name: sharedDataVolumeName, MountPath: supervisordMountPath},
 		},
 	}
 	return container, nil
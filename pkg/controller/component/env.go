@@ -0,0 +1,115 @@
+package component
+
+import (
+	"fmt"
+	component "halkyon.io/api/component/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"sort"
+)
+
+// getEnvValueSources returns the env vars declared using the EnvAnnotation, i.e. the ones which can reference Secret or
+// ConfigMap keys as opposed to the literal values provided by Spec.Envs
+func getEnvValueSources(c *component.Component) ([]corev1.EnvVar, error) {
+	var envs []corev1.EnvVar
+	if _, err := decodeAnnotation(c, EnvAnnotation, &envs); err != nil {
+		return nil, err
+	}
+	for _, env := range envs {
+		if len(env.Name) == 0 {
+			return nil, fmt.Errorf("env var declared in '%s' annotation must provide a name", EnvAnnotation)
+		}
+		if from := env.ValueFrom; from != nil {
+			if ref := from.SecretKeyRef; ref != nil && (len(ref.Name) == 0 || len(ref.Key) == 0) {
+				return nil, fmt.Errorf("'%s' env var must provide both name and key of the referenced Secret", env.Name)
+			}
+			if ref := from.ConfigMapKeyRef; ref != nil && (len(ref.Name) == 0 || len(ref.Key) == 0) {
+				return nil, fmt.Errorf("'%s' env var must provide both name and key of the referenced ConfigMap", env.Name)
+			}
+		}
+	}
+	return envs, nil
+}
+
+// getEnvFromSources returns the Secrets and ConfigMaps the component wants to expose as a whole to its containers
+func getEnvFromSources(c *component.Component) ([]corev1.EnvFromSource, error) {
+	var sources []corev1.EnvFromSource
+	if _, err := decodeAnnotation(c, EnvFromAnnotation, &sources); err != nil {
+		return nil, err
+	}
+	for _, source := range sources {
+		if (source.SecretRef == nil) == (source.ConfigMapRef == nil) {
+			return nil, fmt.Errorf("entries of '%s' annotation must reference either a Secret or a ConfigMap", EnvFromAnnotation)
+		}
+	}
+	return sources, nil
+}
+
+// populatePodEnvVar computes the env vars of the component's container: literal values, including the runtime's defaults,
+// are overridden by the ones declared with a value source. Result is sorted by name so that it can be compared.
+func populatePodEnvVar(component *component.Component) ([]corev1.EnvVar, error) {
+	tmpEnvVar, err := getEnvAsMap(component)
+	if err != nil {
+		return nil, err
+	}
+	valueSources, err := getEnvValueSources(component)
+	if err != nil {
+		return nil, err
+	}
+	for _, env := range valueSources {
+		delete(tmpEnvVar, env.Name)
+	}
+
+	// Convert Map to Slice
+	newEnvVars := make([]corev1.EnvVar, 0, len(tmpEnvVar)+len(valueSources))
+	for k, v := range tmpEnvVar {
+		newEnvVars = append(newEnvVars, corev1.EnvVar{Name: k, Value: v})
+	}
+	newEnvVars = append(newEnvVars, valueSources...)
+
+	return sortedEnv(newEnvVars), nil
+}
+
+// populatePodEnvFrom computes the EnvFrom sources of the component's container, keeping the Secrets which were injected
+// when linking to the required capabilities in addition to the ones declared on the component
+func populatePodEnvFrom(c *component.Component, existing []corev1.EnvFromSource) ([]corev1.EnvFromSource, error) {
+	sources, err := getEnvFromSources(c)
+	if err != nil {
+		return nil, err
+	}
+	for _, source := range existing {
+		if source.SecretRef != nil && isCapabilitySecret(c, source.SecretRef.Name) && !containsEnvFrom(sources, source) {
+			sources = append(sources, source)
+		}
+	}
+	return sources, nil
+}
+
+func isCapabilitySecret(c *component.Component, name string) bool {
+	for _, required := range c.Spec.Capabilities.Requires {
+		if len(required.BoundTo) > 0 && capabilitySecretName(required) == name {
+			return true
+		}
+	}
+	return false
+}
+
+func containsEnvFrom(sources []corev1.EnvFromSource, source corev1.EnvFromSource) bool {
+	for _, s := range sources {
+		if s.SecretRef != nil && source.SecretRef != nil && s.SecretRef.Name == source.SecretRef.Name {
+			return true
+		}
+		if s.ConfigMapRef != nil && source.ConfigMapRef != nil && s.ConfigMapRef.Name == source.ConfigMapRef.Name {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedEnv(envs []corev1.EnvVar) []corev1.EnvVar {
+	sorted := make([]corev1.EnvVar, len(envs))
+	copy(sorted, envs)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
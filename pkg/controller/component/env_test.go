@@ -0,0 +1,152 @@
+package component
+
+import (
+	"halkyon.io/api/component/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"reflect"
+	"testing"
+)
+
+func secretKeyEnv(name, secret, key string) corev1.EnvVar {
+	return corev1.EnvVar{Name: name, ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: secret},
+		Key:                  key,
+	}}}
+}
+
+func secretEnvFrom(name string) corev1.EnvFromSource {
+	return corev1.EnvFromSource{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: name}}}
+}
+
+func configMapEnvFrom(name string) corev1.EnvFromSource {
+	return corev1.EnvFromSource{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: name}}}
+}
+
+func TestGetEnvValueSources(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation string
+		expected   []corev1.EnvVar
+		invalid    bool
+	}{
+		{name: "none"},
+		{
+			name:       "secret and config map keys",
+			annotation: `[{"name": "DB_PASSWORD", "valueFrom": {"secretKeyRef": {"name": "db", "key": "password"}}}, {"name": "MODE", "valueFrom": {"configMapKeyRef": {"name": "settings", "key": "mode"}}}]`,
+			expected: []corev1.EnvVar{secretKeyEnv("DB_PASSWORD", "db", "password"), {Name: "MODE", ValueFrom: &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "settings"},
+				Key:                  "mode",
+			}}}},
+		},
+		{name: "literal value", annotation: `[{"name": "MODE", "value": "prod"}]`, expected: []corev1.EnvVar{{Name: "MODE", Value: "prod"}}},
+		{name: "missing name", annotation: `[{"value": "prod"}]`, invalid: true},
+		{name: "missing secret key", annotation: `[{"name": "DB_PASSWORD", "valueFrom": {"secretKeyRef": {"name": "db"}}}]`, invalid: true},
+		{name: "missing config map name", annotation: `[{"name": "MODE", "valueFrom": {"configMapKeyRef": {"key": "mode"}}}]`, invalid: true},
+		{name: "unknown field", annotation: `[{"name": "MODE", "valu": "prod"}]`, invalid: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestComponent(map[string]string{EnvAnnotation: test.annotation})
+			envs, err := getEnvValueSources(c)
+			if test.invalid {
+				if err == nil {
+					t.Errorf("expected the annotation to be rejected, got %v", envs)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(envs, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, envs)
+			}
+		})
+	}
+}
+
+func TestGetEnvFromSources(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation string
+		expected   []corev1.EnvFromSource
+		invalid    bool
+	}{
+		{name: "none"},
+		{
+			name:       "secret and config map",
+			annotation: `[{"secretRef": {"name": "credentials"}}, {"configMapRef": {"name": "settings"}, "prefix": "APP_"}]`,
+			expected:   []corev1.EnvFromSource{secretEnvFrom("credentials"), {Prefix: "APP_", ConfigMapRef: configMapEnvFrom("settings").ConfigMapRef}},
+		},
+		{name: "both", annotation: `[{"secretRef": {"name": "credentials"}, "configMapRef": {"name": "settings"}}]`, invalid: true},
+		{name: "neither", annotation: `[{"prefix": "APP_"}]`, invalid: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestComponent(map[string]string{EnvFromAnnotation: test.annotation})
+			sources, err := getEnvFromSources(c)
+			if test.invalid {
+				if err == nil {
+					t.Errorf("expected the annotation to be rejected, got %v", sources)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(sources, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, sources)
+			}
+		})
+	}
+}
+
+func TestPopulatePodEnvVar(t *testing.T) {
+	c := newTestComponent(map[string]string{
+		EnvAnnotation: `[{"name": "DB_PASSWORD", "valueFrom": {"secretKeyRef": {"name": "db", "key": "password"}}}]`,
+	})
+	// image mode components without runtime don't get the default env of a runtime
+	c.Spec.DeploymentMode = ImageDeploymentMode
+	c.Spec.Runtime = ""
+	c.Spec.Envs = []v1beta1.NameValuePair{{Name: "MODE", Value: "prod"}, {Name: "DB_PASSWORD", Value: "changeme"}, {Name: "APP_NAME", Value: "backend"}}
+	envs, err := populatePodEnvVar(c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []corev1.EnvVar{{Name: "APP_NAME", Value: "backend"}, secretKeyEnv("DB_PASSWORD", "db", "password"), {Name: "MODE", Value: "prod"}}
+	if !reflect.DeepEqual(envs, expected) {
+		t.Errorf("expected the value sources to override the literal values sorted by name %v, got %v", expected, envs)
+	}
+}
+
+func TestPopulatePodEnvFrom(t *testing.T) {
+	c := newTestComponent(map[string]string{EnvFromAnnotation: `[{"secretRef": {"name": "credentials"}}, {"configMapRef": {"name": "settings"}}]`})
+	required := v1beta1.RequiredCapabilityConfig{}
+	required.BoundTo = "postgres"
+	unbound := v1beta1.RequiredCapabilityConfig{}
+	c.Spec.Capabilities.Requires = []v1beta1.RequiredCapabilityConfig{required, unbound}
+	existing := []corev1.EnvFromSource{
+		secretEnvFrom("credentials"),
+		secretEnvFrom("postgres-config"),
+		// declared on the component before being removed from the annotation
+		secretEnvFrom("previous"),
+		configMapEnvFrom("postgres-config"),
+	}
+	sources, err := populatePodEnvFrom(c, existing)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []corev1.EnvFromSource{secretEnvFrom("credentials"), configMapEnvFrom("settings"), secretEnvFrom("postgres-config")}
+	if !reflect.DeepEqual(sources, expected) {
+		t.Errorf("expected the declared sources and capability secrets %v, got %v", expected, sources)
+	}
+
+	c.Annotations = map[string]string{EnvFromAnnotation: `[{"secretRef": {"name": "postgres-config"}, "prefix": "DB_"}]`}
+	sources, err = populatePodEnvFrom(c, existing)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected = []corev1.EnvFromSource{{Prefix: "DB_", SecretRef: secretEnvFrom("postgres-config").SecretRef}}
+	if !reflect.DeepEqual(sources, expected) {
+		t.Errorf("expected the capability secret declared on the component not to be duplicated, got %v", sources)
+	}
+}
@@ -0,0 +1,154 @@
+package component
+
+import (
+	"context"
+	"fmt"
+	"halkyon.io/api/component/v1beta1"
+	framework "halkyon.io/operator-framework"
+	"halkyon.io/operator/pkg/git"
+	"halkyon.io/operator/pkg/tekton"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"strconv"
+	"strings"
+)
+
+const (
+	// tektonGitAnnotationPrefix prefixes the annotations telling Tekton which git host the credentials of a Secret linked
+	// to the build service account are for
+	tektonGitAnnotationPrefix = "tekton.dev/git-"
+	// gitAuthenticationExitCode is the exit code of the clone step when the git repository rejected the credentials
+	gitAuthenticationExitCode = 3
+	// GitAuthenticationFailedReason is the reason of the build condition when the git repository couldn't be cloned for
+	// lack of valid credentials
+	GitAuthenticationFailedReason = "GitAuthenticationFailed"
+)
+
+// gitSecretName returns the name of the Secret holding the credentials used to access the component's git repository
+func gitSecretName(c *v1beta1.Component) (string, bool) {
+	return getAnnotation(c, GitSecretAnnotation)
+}
+
+// fetchGitSecret returns the Secret holding the credentials used to access the component's git repository, checking
+// that its type suits the repository URL
+func fetchGitSecret(c *v1beta1.Component) (*corev1.Secret, error) {
+	name, _ := gitSecretName(c)
+	secret := &corev1.Secret{}
+	if err := framework.Helper.Client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: c.Namespace}, secret); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, fmt.Errorf("'%s' git credentials Secret of component '%s' doesn't exist", name, c.Name)
+		}
+		return nil, err
+	}
+	url := c.Spec.BuildConfig.URL
+	switch scheme := git.Scheme(url); secret.Type {
+	case corev1.SecretTypeBasicAuth:
+		if scheme != "https" && scheme != "http" {
+			return nil, fmt.Errorf("'%s' git credentials Secret of component '%s' holds basic-auth credentials which cannot be used to access %s", name, c.Name, url)
+		}
+	case corev1.SecretTypeSSHAuth:
+		if scheme != "ssh" {
+			return nil, fmt.Errorf("'%s' git credentials Secret of component '%s' holds an SSH key which cannot be used to access %s", name, c.Name, url)
+		}
+	default:
+		return nil, fmt.Errorf("'%s' git credentials Secret of component '%s' must be of type %s or %s", name, c.Name, corev1.SecretTypeBasicAuth, corev1.SecretTypeSSHAuth)
+	}
+	return secret, nil
+}
+
+// prepareGitCredentials annotates the component's git credentials Secret, if any, following Tekton's conventions so that
+// Tekton provides its credentials to the clone step once the Secret is linked to the build service account
+func prepareGitCredentials(c *v1beta1.Component) error {
+	if _, found := gitSecretName(c); !found {
+		return nil
+	}
+	secret, err := fetchGitSecret(c)
+	if err != nil {
+		return err
+	}
+	// Tekton expects the scheme and host of the repository for basic-auth credentials, only its host for SSH keys
+	url := c.Spec.BuildConfig.URL
+	host := git.Host(url)
+	if secret.Type == corev1.SecretTypeBasicAuth {
+		host = git.Scheme(url) + "://" + host
+	}
+	for key, value := range secret.Annotations {
+		if strings.HasPrefix(key, tektonGitAnnotationPrefix) && value == host {
+			return nil
+		}
+	}
+	index := 0
+	for len(secret.Annotations[tektonGitAnnotationPrefix+strconv.Itoa(index)]) > 0 {
+		index++
+	}
+	if secret.Annotations == nil {
+		secret.Annotations = make(map[string]string, 1)
+	}
+	secret.Annotations[tektonGitAnnotationPrefix+strconv.Itoa(index)] = host
+	return framework.Helper.Client.Update(context.TODO(), secret)
+}
+
+// gitCredentials returns the basic-auth credentials used to access the component's git repository, if any
+func gitCredentials(c *v1beta1.Component) (username, password string) {
+	if _, found := gitSecretName(c); !found {
+		return "", ""
+	}
+	secret, err := fetchGitSecret(c)
+	if err != nil || secret.Type != corev1.SecretTypeBasicAuth {
+		return "", ""
+	}
+	return string(secret.Data[corev1.BasicAuthUsernameKey]), string(secret.Data[corev1.BasicAuthPasswordKey])
+}
+
+// gitSecrets returns the secret values held by the component's git credentials Secret, if any: the password of
+// basic-auth credentials or the lines of the private key of SSH credentials
+func gitSecrets(c *v1beta1.Component) []string {
+	if _, found := gitSecretName(c); !found {
+		return nil
+	}
+	secret, err := fetchGitSecret(c)
+	if err != nil {
+		return nil
+	}
+	switch secret.Type {
+	case corev1.SecretTypeBasicAuth:
+		return []string{string(secret.Data[corev1.BasicAuthPasswordKey])}
+	case corev1.SecretTypeSSHAuth:
+		return privateKeyLines(string(secret.Data[corev1.SSHAuthPrivateKey]))
+	}
+	return nil
+}
+
+// privateKeyLines returns the lines of the specified PEM encoded private key, without its armor lines, so that the key
+// gets redacted from logs which print it line by line
+func privateKeyLines(key string) []string {
+	lines := strings.Split(key, "\n")
+	secrets := make([]string, 0, len(lines))
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if len(line) > 0 && !strings.HasPrefix(line, "-----") {
+			secrets = append(secrets, line)
+		}
+	}
+	return secrets
+}
+
+// isGitAuthenticationFailure returns whether the specified build failed because the git repository rejected its
+// credentials, as reported by the exit code of the clone step
+func isGitAuthenticationFailure(tr *tekton.TaskRun) bool {
+	for _, step := range tr.Status.Steps {
+		if step.Name == cloneStepName && step.Terminated != nil {
+			return step.Terminated.ExitCode == gitAuthenticationExitCode
+		}
+	}
+	return false
+}
+
+// gitAuthenticationFailureMessage explains why the component's git repository couldn't be cloned
+func gitAuthenticationFailureMessage(c *v1beta1.Component) string {
+	if name, found := gitSecretName(c); found {
+		return fmt.Sprintf("%s rejected the credentials of the '%s' Secret", c.Spec.BuildConfig.URL, name)
+	}
+	return fmt.Sprintf("%s requires credentials: reference a Secret holding them using the '%s' annotation", c.Spec.BuildConfig.URL, GitSecretAnnotation)
+}
@@ -3,13 +3,11 @@ package component
 import (
 	component "halkyon.io/api/component/v1beta1"
 	"halkyon.io/api/v1beta1"
-	"halkyon.io/operator-framework"
 	"os"
 )
 
 const (
-	RegistryAddressEnvVar = "REGISTRY_ADDRESS"
-	BaseS2iImage          = "BASE_S2I_IMAGE"
+	BaseS2iImage = "BASE_S2I_IMAGE"
 )
 
 func getEnvAsMap(component *component.Component) (map[string]string, error) {
@@ -20,6 +18,10 @@ func getEnvAsMap(component *component.Component) (map[string]string, error) {
 		tmpEnvVar[v.Name] = v.Value
 	}
 
+	if !needsRuntime(component) {
+		return tmpEnvVar, nil
+	}
+
 	image, err := getImageInfo(component)
 	if err != nil {
 		return map[string]string{}, err
@@ -52,6 +54,12 @@ func populateEnvVar(component *component.Component) error {
 	return nil
 }
 
+// needsRuntime checks whether the component relies on its runtime: components deploying a prebuilt image only use it, if
+// specified, to get default env values
+func needsRuntime(component *component.Component) bool {
+	return !isImageDeploymentMode(component) || len(component.Spec.Runtime) > 0
+}
+
 //getAppLabels returns a string map with the Application labels which will be associated to the kubernetes/ocp resource created and managed by this operator
 func getAppLabels(component *component.Component) map[string]string {
 	name := component.DeploymentName()
@@ -120,20 +128,11 @@ func gitRevision(c *component.Component) string {
 	}
 }
 
-func dockerImageURL(c *component.Component) string {
-	// Try to find the registry env var
-	registry, found := os.LookupEnv(RegistryAddressEnvVar)
-	if found {
-		return registry + "/" + c.Namespace + "/" + c.Name
-	}
-	// Revert to default values if no ENV var is set
-	if framework.IsTargetClusterRunningOpenShift() {
-		if framework.OpenShiftVersion() == 4 {
-			return "image-registry.openshift-image-registry.svc:5000/" + c.Namespace + "/" + c.Name
-		} else {
-			return "docker-registry.default.svc:5000/" + c.Namespace + "/" + c.Name
-		}
-	} else {
-		return "kube-registry.kube-system.svc:5000/" + c.Namespace + "/" + c.Name
+// dockerImageURL returns the reference of the image built for the component, as configured for its namespace
+func dockerImageURL(c *component.Component) (string, error) {
+	config, err := getRegistryConfig(c.Namespace)
+	if err != nil {
+		return "", err
 	}
+	return config.imageURL(c)
 }
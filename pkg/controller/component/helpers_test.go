@@ -0,0 +1,26 @@
+package component
+
+import (
+	"halkyon.io/api/component/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// newTestComponent returns a build mode component with the specified annotations
+func newTestComponent(annotations map[string]string) *v1beta1.Component {
+	c := &v1beta1.Component{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "backend",
+			Namespace:   "demo",
+			Annotations: annotations,
+		},
+		Spec: v1beta1.ComponentSpec{
+			DeploymentMode: v1beta1.BuildDeploymentMode,
+			Runtime:        "spring-boot",
+			Version:        "2.1.6.RELEASE",
+			Port:           8080,
+		},
+	}
+	c.Spec.BuildConfig.URL = "https://github.com/halkyonio/operator-samples.git"
+	c.Spec.BuildConfig.Ref = "master"
+	return c
+}
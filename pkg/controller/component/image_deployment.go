@@ -0,0 +1,116 @@
+package component
+
+import (
+	component "halkyon.io/api/component/v1beta1"
+	"halkyon.io/operator/pkg/registry"
+	"k8s.io/api/apps/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ImageDeploymentMode deploys a prebuilt image, specified using the ImageAnnotation, without building it nor setting up
+// the dev mode tooling
+const ImageDeploymentMode = "image"
+
+func isImageDeploymentMode(c *component.Component) bool {
+	return ImageDeploymentMode == c.Spec.DeploymentMode
+}
+
+func isDevDeploymentMode(c *component.Component) bool {
+	return component.BuildDeploymentMode != c.Spec.DeploymentMode && !isImageDeploymentMode(c)
+}
+
+// installImage returns the Deployment config object to be used for deployment using a prebuilt container image
+func (res deployment) installImage(empty bool) (runtime.Object, error) {
+	dep := &appsv1.Deployment{}
+	if !empty {
+		c := res.ownerAsComponent()
+		ls := getAppLabels(c)
+
+		// create runtime container using the specified image
+		runtimeContainer, err := getPrebuiltContainerFor(c)
+		if err != nil {
+			return nil, err
+		}
+		runtimeContainer.Ports = []corev1.ContainerPort{{
+			ContainerPort: c.Spec.Port,
+			Name:          "http",
+			Protocol:      "TCP",
+		}}
+
+		// add the volumes and containers requested by the user
+		userVolumes, userMounts, err := getUserVolumes(c)
+		if err != nil {
+			return nil, err
+		}
+		runtimeContainer.VolumeMounts = append(runtimeContainer.VolumeMounts, userMounts...)
+		sidecars, initContainers, err := getUserContainers(c)
+		if err != nil {
+			return nil, err
+		}
+		scheduling, err := getScheduling(c, SchedulingAnnotation)
+		if err != nil {
+			return nil, err
+		}
+
+		dep.ObjectMeta = metav1.ObjectMeta{
+			Name:      res.Name(),
+			Namespace: c.Namespace,
+			Labels:    ls,
+		}
+		dep.Spec = v1.DeploymentSpec{
+			Strategy: v1.DeploymentStrategy{
+				Type: v1.RollingUpdateDeploymentStrategyType,
+			},
+			Selector: &metav1.LabelSelector{
+				MatchLabels: ls,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      ls,
+					Name:        c.Name,
+					Annotations: podTemplateAnnotations(c),
+				},
+				Spec: corev1.PodSpec{
+					Containers:       append([]corev1.Container{runtimeContainer}, sidecars...),
+					InitContainers:   initContainers,
+					ImagePullSecrets: imagePullSecrets(c),
+					Volumes:          userVolumes,
+				}},
+		}
+		scheduling.applyTo(&dep.Spec.Template.Spec)
+		if err := applyPodSecurity(c, &dep.Spec.Template); err != nil {
+			return nil, err
+		}
+	}
+
+	return dep, nil
+}
+
+func getPrebuiltContainerFor(component *component.Component) (corev1.Container, error) {
+	env, err := populatePodEnvVar(component)
+	if err != nil {
+		return corev1.Container{}, err
+	}
+	envFrom, err := populatePodEnvFrom(component, nil)
+	if err != nil {
+		return corev1.Container{}, err
+	}
+	prebuilt, _ := getAnnotation(component, ImageAnnotation)
+	image, pinned := pinImage(component, prebuilt, imagePullSecrets(component), registry.TLS{})
+	pullPolicy, err := imagePullPolicy(component, pinned)
+	if err != nil {
+		return corev1.Container{}, err
+	}
+
+	container := corev1.Container{
+		Env:             env,
+		EnvFrom:         envFrom,
+		Image:           image,
+		ImagePullPolicy: pullPolicy,
+		Name:            component.Name,
+	}
+	return container, nil
+}
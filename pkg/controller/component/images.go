@@ -0,0 +1,132 @@
+package component
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	component "halkyon.io/api/component/v1beta1"
+	framework "halkyon.io/operator-framework"
+	"halkyon.io/operator/pkg/registry"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"strings"
+	"time"
+)
+
+const (
+	// ImageAttributeKey is the condition attribute recording the image deployed for the component
+	ImageAttributeKey = "Image"
+	// ImageDigestAttributeKey is the condition attribute recording the digest of the image deployed for the component
+	ImageDigestAttributeKey = "ImageDigest"
+	// BuildAttributeKey is the condition attribute recording the name of the build which produced the deployed image
+	BuildAttributeKey = "Build"
+)
+
+var imageResolver = registry.NewResolver(5 * time.Minute)
+
+// pinImage returns the specified image pinned to the digest it currently points to, along with whether pinning occurred.
+// The image is returned as is if the component opted out of pinning or if its digest cannot be resolved, e.g. because it
+// hasn't been built yet.
+func pinImage(c *component.Component, image string, pullSecrets []corev1.LocalObjectReference, verify registry.TLS) (string, bool) {
+	if pin, found := getAnnotation(c, PinImagesAnnotation); found && pin == "false" {
+		return image, false
+	}
+	if strings.Contains(image, "@") {
+		return image, true
+	}
+	digest, err := imageResolver.Digest(image, registryKeychain(c.Namespace, pullSecrets), verify)
+	if err != nil {
+		return image, false
+	}
+	ref, _ := registry.ParseReference(image) // image has already been successfully parsed when resolving its digest
+	return ref.WithDigest(digest), true
+}
+
+func imagePullPolicy(c *component.Component, pinned bool) (corev1.PullPolicy, error) {
+	if policy, found := getAnnotation(c, ImagePullPolicyAnnotation); found {
+		switch corev1.PullPolicy(policy) {
+		case corev1.PullAlways, corev1.PullIfNotPresent, corev1.PullNever:
+			return corev1.PullPolicy(policy), nil
+		default:
+			return "", fmt.Errorf("unknown '%s' image pull policy, must be one of: %s, %s, %s", policy, corev1.PullAlways, corev1.PullIfNotPresent, corev1.PullNever)
+		}
+	}
+	// an image pinned by digest cannot change so there's no need to pull it again
+	if pinned {
+		return corev1.PullIfNotPresent, nil
+	}
+	return corev1.PullAlways, nil
+}
+
+// registryKeychain returns a Keychain looking up credentials in the specified docker config secrets
+func registryKeychain(namespace string, pullSecrets []corev1.LocalObjectReference) registry.Keychain {
+	return func(host string) (registry.Credentials, bool) {
+		for _, ref := range pullSecrets {
+			secret := &corev1.Secret{}
+			if err := framework.Helper.Client.Get(context.TODO(), types.NamespacedName{Name: ref.Name, Namespace: namespace}, secret); err != nil {
+				continue
+			}
+			if credentials, found := dockerConfigCredentials(secret, host); found {
+				return credentials, true
+			}
+		}
+		return registry.Credentials{}, false
+	}
+}
+
+type dockerAuth struct {
+	Auth     string `json:"auth"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// dockerConfigAuths extracts the credentials of each registry from a dockercfg or dockerconfigjson secret
+func dockerConfigAuths(secret *corev1.Secret) map[string]dockerAuth {
+	auths := make(map[string]dockerAuth)
+	if data, ok := secret.Data[corev1.DockerConfigJsonKey]; ok {
+		config := struct {
+			Auths map[string]dockerAuth `json:"auths"`
+		}{}
+		if json.Unmarshal(data, &config) != nil {
+			return nil
+		}
+		auths = config.Auths
+	} else if data, ok := secret.Data[corev1.DockerConfigKey]; ok {
+		if json.Unmarshal(data, &auths) != nil {
+			return nil
+		}
+	}
+	return auths
+}
+
+// dockerConfigCredentials extracts the credentials for the specified registry host from a dockercfg or dockerconfigjson secret
+func dockerConfigCredentials(secret *corev1.Secret, host string) (registry.Credentials, bool) {
+	for server, auth := range dockerConfigAuths(secret) {
+		if registryHost(server) != registryHost(host) {
+			continue
+		}
+		if len(auth.Username) > 0 {
+			return registry.Credentials{Username: auth.Username, Password: auth.Password}, true
+		}
+		if decoded, err := base64.StdEncoding.DecodeString(auth.Auth); err == nil {
+			if parts := strings.SplitN(string(decoded), ":", 2); len(parts) == 2 {
+				return registry.Credentials{Username: parts[0], Password: parts[1]}, true
+			}
+		}
+	}
+	return registry.Credentials{}, false
+}
+
+// registryHost normalizes docker config server keys, which can be URLs, and Docker Hub aliases
+func registryHost(server string) string {
+	server = strings.TrimPrefix(strings.TrimPrefix(server, "https://"), "http://")
+	if i := strings.IndexRune(server, '/'); i >= 0 {
+		server = server[:i]
+	}
+	switch server {
+	case "index.docker.io", "registry-1.docker.io":
+		return "docker.io"
+	}
+	return server
+}
@@ -0,0 +1,140 @@
+package component
+
+import (
+	"fmt"
+	"halkyon.io/api/component/v1beta1"
+	"halkyon.io/operator/pkg/git"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// buildTagPrefix prefixes the tag identifying the image pushed by a build using its number, e.g. build-3
+	buildTagPrefix = "build-"
+	maxTagLength   = 128
+)
+
+var (
+	tagPattern          = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+	invalidTagCharacter = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+)
+
+// Promotion describes the promotion of the image a tag of the component's repository points to, e.g. staging, to
+// another tag, e.g. prod
+type Promotion struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// buildTags returns the tags the current build of the component pushes its image with in addition to the commit SHA,
+// only known once the project is cloned: its build number and, unless a commit or a source archive is built, the branch
+// or tag it built
+func buildTags(c *v1beta1.Component) []string {
+	tags := []string{buildTagPrefix + strconv.Itoa(buildNumber(c))}
+	if revision := gitRevision(c); !git.IsCommitSHA(revision) && !isSourceArchiveBuild(c) {
+		if tag := branchTag(revision); len(tag) > 0 {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// branchTag turns the specified git branch or tag into an image tag, replacing the characters image tags cannot contain,
+// e.g. feature/login becomes feature-login
+func branchTag(revision string) string {
+	revision = strings.TrimPrefix(strings.TrimPrefix(revision, "refs/heads/"), "refs/tags/")
+	tag := strings.TrimLeft(invalidTagCharacter.ReplaceAllString(revision, "-"), ".-")
+	if len(tag) > maxTagLength {
+		tag = tag[:maxTagLength]
+	}
+	return tag
+}
+
+// checkTag validates the specified image tag, set on the component using the specified annotation
+func checkTag(c *v1beta1.Component, annotation, tag string) error {
+	if !tagPattern.MatchString(tag) {
+		return fmt.Errorf("invalid '%s' annotation on component '%s': '%s' is not a valid image tag", annotation, c.Name, tag)
+	}
+	return nil
+}
+
+// deployedTag returns the validated tag of the component's repository whose image is deployed, as specified using the
+// DeployedTagAnnotation
+func deployedTag(c *v1beta1.Component) (string, bool, error) {
+	tag, found := getAnnotation(c, DeployedTagAnnotation)
+	if !found {
+		return "", false, nil
+	}
+	return tag, true, checkTag(c, DeployedTagAnnotation, tag)
+}
+
+// requestedPromotion returns the validated promotion requested using the PromoteAnnotation, nil if none
+func requestedPromotion(c *v1beta1.Component) (*Promotion, error) {
+	promotion := &Promotion{}
+	if found, err := decodeAnnotation(c, PromoteAnnotation, promotion); !found || err != nil {
+		return nil, err
+	}
+	if err := checkTag(c, PromoteAnnotation, promotion.From); err != nil {
+		return nil, err
+	}
+	if err := checkTag(c, PromoteAnnotation, promotion.To); err != nil {
+		return nil, err
+	}
+	return promotion, nil
+}
+
+// promoteImage performs the promotion requested using the PromoteAnnotation, if any: the target tag is pointed to the
+// image the source tag points to, without rebuilding it, and the component then deploys the target tag, recorded using
+// the DeployedTagAnnotation. The PromoteAnnotation is removed once done. Returns whether the component was modified and
+// needs to be updated.
+func promoteImage(c *v1beta1.Component) (bool, error) {
+	promotion, err := requestedPromotion(c)
+	if err != nil || promotion == nil {
+		return false, err
+	}
+	image, err := dockerImageURL(c)
+	if err != nil {
+		return false, err
+	}
+	verify, err := registryTLS(c)
+	if err != nil {
+		return false, err
+	}
+	digest, err := imageResolver.Tag(image+":"+promotion.From, promotion.To, registryKeychain(c.Namespace, imagePullSecrets(c)), verify)
+	if err != nil {
+		return false, err
+	}
+	log.Info(fmt.Sprintf("promoted '%s' image from '%s' to '%s' tag", image+"@"+digest, promotion.From, promotion.To), "component", c.Name, "namespace", c.Namespace)
+	c.Annotations[DeployedTagAnnotation] = promotion.To
+	delete(c.Annotations, PromoteAnnotation)
+	return true, nil
+}
+
+// taggedImage returns the image the specified tag of the component's repository points to, along with whether it's
+// pinned by digest and the name of the build which produced it, if it was built by one of the component's builds still
+// kept
+func taggedImage(c *v1beta1.Component, tag string) (image string, pinned bool, build string, err error) {
+	if image, err = dockerImageURL(c); err != nil {
+		return "", false, "", err
+	}
+	verify, err := registryTLS(c)
+	if err != nil {
+		return "", false, "", err
+	}
+	image, pinned = pinImage(c, image+":"+tag, imagePullSecrets(c), verify)
+	if !pinned {
+		return image, false, "", nil
+	}
+	digest := image[strings.LastIndex(image, "@")+1:]
+	builds, err := listBuilds(c)
+	if err != nil {
+		return image, true, "", nil
+	}
+	for _, tr := range builds {
+		if buildImageDigest(&tr) == digest {
+			return image, true, tr.Name, nil
+		}
+	}
+	return image, true, "", nil
+}
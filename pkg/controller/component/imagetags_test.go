@@ -0,0 +1,89 @@
+package component
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBranchTag(t *testing.T) {
+	tests := map[string]string{
+		"master":                      "master",
+		"refs/heads/master":           "master",
+		"refs/tags/v1.0":              "v1.0",
+		"feature/login":               "feature-login",
+		"refs/heads/fix/issue#42":     "fix-issue-42",
+		".hidden":                     "hidden",
+		"-draft":                      "draft",
+		"release_1.2-rc":              "release_1.2-rc",
+		strings.Repeat("branch", 30):  strings.Repeat("branch", 30)[:maxTagLength],
+		"feature/été":                 "feature--t-",
+		"refs/heads/users/jane/topic": "users-jane-topic",
+	}
+	for revision, expected := range tests {
+		tag := branchTag(revision)
+		if tag != expected {
+			t.Errorf("expected '%s' to be tagged %s, got %s", revision, expected, tag)
+		}
+		if !tagPattern.MatchString(tag) {
+			t.Errorf("expected '%s' tag of '%s' to be a valid image tag", tag, revision)
+		}
+	}
+}
+
+func TestCheckTag(t *testing.T) {
+	c := newTestComponent(nil)
+	for _, tag := range []string{"latest", "prod", "v1.0", "build-3", "_internal", strings.Repeat("a", 128)} {
+		if err := checkTag(c, DeployedTagAnnotation, tag); err != nil {
+			t.Errorf("expected '%s' tag to be valid: %v", tag, err)
+		}
+	}
+	for _, tag := range []string{"", ".hidden", "-draft", "feature/login", "v1:0", strings.Repeat("a", 129)} {
+		if err := checkTag(c, DeployedTagAnnotation, tag); err == nil {
+			t.Errorf("expected '%s' tag to be rejected", tag)
+		}
+	}
+}
+
+func TestBuildTags(t *testing.T) {
+	tests := []struct {
+		name     string
+		ref      string
+		archive  bool
+		expected []string
+	}{
+		{name: "branch", ref: "feature/login", expected: []string{"build-3", "feature-login"}},
+		{name: "default branch", expected: []string{"build-3", "master"}},
+		{name: "commit", ref: "0123456789abcdef0123456789abcdef01234567", expected: []string{"build-3"}},
+		{name: "source archive", ref: "master", archive: true, expected: []string{"build-3"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			annotations := map[string]string{BuildNumberAnnotation: "3"}
+			if test.archive {
+				annotations[SourceArchiveAnnotation] = `{"configMap": "backend-source-0123456789ab", "path": "source"}`
+			}
+			c := newTestComponent(annotations)
+			c.Spec.BuildConfig.Ref = test.ref
+			if tags := buildTags(c); !reflect.DeepEqual(tags, test.expected) {
+				t.Errorf("expected %v tags, got %v", test.expected, tags)
+			}
+		})
+	}
+}
+
+func TestRequestedPromotion(t *testing.T) {
+	if promotion, err := requestedPromotion(newTestComponent(nil)); promotion != nil || err != nil {
+		t.Errorf("expected no promotion by default, got %+v: %v", promotion, err)
+	}
+	c := newTestComponent(map[string]string{PromoteAnnotation: `{"from": "staging", "to": "prod"}`})
+	if promotion, err := requestedPromotion(c); err != nil || promotion == nil || promotion.From != "staging" || promotion.To != "prod" {
+		t.Errorf("expected promotion from staging to prod, got %+v: %v", promotion, err)
+	}
+	for _, value := range []string{`{"from": "staging"}`, `{"from": "staging", "to": "prod/eu"}`, `{"from": "staging", "to": "prod", "force": true}`, `staging`} {
+		c := newTestComponent(map[string]string{PromoteAnnotation: value})
+		if promotion, err := requestedPromotion(c); err == nil {
+			t.Errorf("expected '%s' promotion to be rejected, got %+v", value, promotion)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package component
+
+import (
+	"context"
+	framework "halkyon.io/operator-framework"
+	"halkyon.io/operator/pkg/tekton"
+	corev1 "k8s.io/api/core/v1"
+	authorizv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+	"sync"
+)
+
+// the names of the build Task, service account, Role and RoleBinding shared by the components of a namespace before each
+// component got its own
+const (
+	legacyTaskName           = "s2i-buildah-push"
+	legacyServiceAccountName = "build-bot"
+	legacyRoleName           = "image-scc-privileged-role"
+	legacyRoleBindingName    = "use-image-scc-privileged"
+)
+
+// legacyCleanups records the namespaces whose legacy build resources were cleaned up. The lock only guards the map: the
+// cleanups of different namespaces don't wait for each other and concurrent cleanups of a namespace are harmless as
+// resources already deleted are skipped.
+var legacyCleanups = struct {
+	sync.Mutex
+	namespaces map[string]bool
+}{namespaces: make(map[string]bool)}
+
+func legacyCleanupDone(namespace string) bool {
+	legacyCleanups.Lock()
+	defer legacyCleanups.Unlock()
+	return legacyCleanups.namespaces[namespace]
+}
+
+// cleanupLegacyBuildResources deletes, once per namespace, the build resources which used to be shared by the
+// components of the namespace and which no component references anymore. The cleanup is postponed while builds started
+// before the upgrade still run them. Only resources created by the operator, i.e. owned by a component, are deleted.
+func cleanupLegacyBuildResources(namespace string) error {
+	if legacyCleanupDone(namespace) {
+		return nil
+	}
+
+	taskRuns := &tekton.TaskRunList{}
+	lo := &client.ListOptions{}
+	lo.InNamespace(namespace)
+	if err := framework.Helper.Client.List(context.TODO(), lo, taskRuns); err != nil {
+		return err
+	}
+	for i := range taskRuns.Items {
+		if runsLegacyBuild(&taskRuns.Items[i]) {
+			return nil
+		}
+	}
+
+	legacy := []struct {
+		name   string
+		object runtime.Object
+	}{
+		{name: legacyTaskName, object: &tekton.Task{}},
+		{name: legacyRoleBindingName, object: &authorizv1.RoleBinding{}},
+		{name: legacyRoleName, object: &authorizv1.Role{}},
+		{name: legacyServiceAccountName, object: &corev1.ServiceAccount{}},
+	}
+	for _, resource := range legacy {
+		if _, err := framework.Helper.Fetch(resource.name, namespace, resource.object); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		if !ownedByComponent(resource.object.(metav1.Object)) {
+			continue
+		}
+		if err := framework.Helper.Client.Delete(context.TODO(), resource.object); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		log.Info("deleted legacy shared build resource", "name", resource.name, "namespace", namespace)
+	}
+	legacyCleanups.Lock()
+	legacyCleanups.namespaces[namespace] = true
+	legacyCleanups.Unlock()
+	return nil
+}
+
+// runsLegacyBuild returns whether the specified TaskRun is still running the legacy shared build Task or service account
+func runsLegacyBuild(tr *tekton.TaskRun) bool {
+	return !isCompleted(tr) && (tr.Spec.ServiceAccountName == legacyServiceAccountName || (tr.Spec.TaskRef != nil && tr.Spec.TaskRef.Name == legacyTaskName))
+}
+
+func ownedByComponent(object metav1.Object) bool {
+	for _, ref := range object.GetOwnerReferences() {
+		if ref.Kind == "Component" && strings.HasPrefix(ref.APIVersion, "halkyon.io/") {
+			return true
+		}
+	}
+	return false
+}
@@ -13,10 +13,14 @@ func PVCName(c *halkyon.Component) string {
 	return "m2-data-" + c.Name // todo: use better default name?
 }
 
+// ServiceAccountName returns the name of the service account running the builds of the specified component: each
+// component gets its own so that the secrets and permissions granted to its builds don't leak to other components
 func ServiceAccountName(owner framework.SerializableResource) string {
-	return "build-bot"
+	return owner.GetName() + "-build-bot"
 }
 
+// TaskName returns the name of the Task building the specified component. Each component gets its own Task, owned by the
+// component, as its steps depend on the component's build configuration.
 func TaskName(owner framework.SerializableResource) string {
-	return "s2i-buildah-push"
+	return owner.GetName() + "-build"
 }
@@ -20,7 +20,7 @@ var _ framework.DependentResource = &pod{}
 
 func newPod(owner *v1beta1.Component) pod {
 	config := framework.NewConfig(v1beta1.PodGVK)
-	config.CheckedForReadiness = v1beta1.DevDeploymentMode == owner.Spec.DeploymentMode
+	config.CheckedForReadiness = v1beta1.DevDeploymentMode == owner.Spec.DeploymentMode || isImageDeploymentMode(owner)
 	config.Created = false
 	return pod{base: newConfiguredBaseDependent(owner, config)}
 }
@@ -48,26 +48,11 @@ func (res pod) GetCondition(underlying runtime.Object, err error) *beta1.Depende
 						var notReadyContainers []string
 						if openBracket > 1 {
 							containerList := c.Message[openBracket+1 : strings.IndexRune(c.Message, ']')]
-							notReadyContainers = strings.Split(containerList, ",")
-						}
-						msgArr := make([]string, 0, len(notReadyContainers))
-						for _, c := range notReadyContainers {
-							for _, status := range p.Status.ContainerStatuses {
-								waiting := status.State.Waiting
-								if status.Name == c && waiting != nil {
-									format := "%s: %s"
-									waitMsg := waiting.Message
-									var m string
-									if len(waitMsg) > 0 {
-										format = format + " => %s"
-										m = fmt.Sprintf(format, c, waiting.Reason, waitMsg)
-									} else {
-										m = fmt.Sprintf(format, c, waiting.Reason)
-									}
-									msgArr = append(msgArr, m)
-								}
-							}
+							notReadyContainers = strings.Fields(strings.ReplaceAll(containerList, ",", " "))
 						}
+						// init containers, user-defined ones in particular, prevent the other containers from starting
+						msgArr := notReadyContainerMessages(p.Status.InitContainerStatuses, nil)
+						msgArr = append(msgArr, notReadyContainerMessages(p.Status.ContainerStatuses, notReadyContainers)...)
 						msg = strings.Join(msgArr, " & ")
 					} else {
 						msg = c.Message
@@ -112,3 +97,39 @@ func (res pod) Fetch() (runtime.Object, error) {
 		}
 	}
 }
+
+// notReadyContainerMessages describes why the specified containers are not ready, considering all containers if names is nil
+func notReadyContainerMessages(statuses []corev1.ContainerStatus, names []string) []string {
+	msgArr := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		if names != nil && !contains(names, status.Name) {
+			continue
+		}
+		if waiting := status.State.Waiting; waiting != nil {
+			format := "%s: %s"
+			waitMsg := waiting.Message
+			var m string
+			if len(waitMsg) > 0 {
+				format = format + " => %s"
+				m = fmt.Sprintf(format, status.Name, waiting.Reason, waitMsg)
+			} else {
+				m = fmt.Sprintf(format, status.Name, waiting.Reason)
+			}
+			msgArr = append(msgArr, m)
+		} else if terminated := status.State.Terminated; terminated != nil && terminated.ExitCode != 0 {
+			msgArr = append(msgArr, fmt.Sprintf("%s: %s (exit code %d)", status.Name, terminated.Reason, terminated.ExitCode))
+		} else if status.State.Running != nil && !status.Ready && names != nil {
+			msgArr = append(msgArr, fmt.Sprintf("%s: not ready", status.Name))
+		}
+	}
+	return msgArr
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
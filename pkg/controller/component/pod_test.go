@@ -0,0 +1,34 @@
+package component
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"reflect"
+	"testing"
+)
+
+func TestNotReadyContainerMessages(t *testing.T) {
+	initStatuses := []corev1.ContainerStatus{
+		{Name: "copy-supervisord", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "Completed"}}},
+		{Name: "migrate", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "Error", ExitCode: 1}}},
+		{Name: "wait-db", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "PodInitializing"}}},
+		{Name: "fetch", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ErrImagePull", Message: "manifest unknown"}}},
+		{Name: "running", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+	}
+	expected := []string{"migrate: Error (exit code 1)", "wait-db: PodInitializing", "fetch: ErrImagePull => manifest unknown"}
+	if messages := notReadyContainerMessages(initStatuses, nil); !reflect.DeepEqual(messages, expected) {
+		t.Errorf("expected %v init container messages, got %v", expected, messages)
+	}
+
+	statuses := []corev1.ContainerStatus{
+		{Name: "backend", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+		{Name: "proxy", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}},
+		{Name: "metrics", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}, Ready: true},
+	}
+	expected = []string{"backend: not ready", "proxy: CrashLoopBackOff"}
+	if messages := notReadyContainerMessages(statuses, []string{"backend", "proxy"}); !reflect.DeepEqual(messages, expected) {
+		t.Errorf("expected %v container messages, got %v", expected, messages)
+	}
+	if messages := notReadyContainerMessages(statuses, []string{"metrics"}); len(messages) != 0 {
+		t.Errorf("expected ready containers not to be reported, got %v", messages)
+	}
+}
@@ -0,0 +1,107 @@
+package component
+
+import (
+	"encoding/json"
+	"fmt"
+	component "halkyon.io/api/component/v1beta1"
+	"halkyon.io/operator/pkg/tekton"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// podTemplateExtensions holds the fields of the pod template of the component's Deployment which the Kubernetes API the
+// operator is built against predates, added in Kubernetes 1.19
+type podTemplateExtensions struct {
+	Spec struct {
+		Template struct {
+			Spec struct {
+				SecurityContext struct {
+					SeccompProfile *tekton.SeccompProfile `json:"seccompProfile"`
+				} `json:"securityContext"`
+				TopologySpreadConstraints []tekton.TopologySpreadConstraint `json:"topologySpreadConstraints"`
+			} `json:"spec"`
+		} `json:"template"`
+	} `json:"spec"`
+}
+
+var podTemplateExtensionsSupport struct {
+	sync.Once
+	supported bool
+}
+
+// desiredPodTemplateExtensions returns the extensions of the pod template of the component: the runtime's default
+// seccomp profile of restricted pods, set in addition to the deprecated annotation set by applyPodSecurity, and the
+// topology spread constraints specified using the SchedulingAnnotation
+func desiredPodTemplateExtensions(c *component.Component) (podTemplateExtensions, error) {
+	extensions := podTemplateExtensions{}
+	security, err := podSecurity(c)
+	if err != nil {
+		return extensions, err
+	}
+	if security == RestrictedPodSecurity {
+		extensions.Spec.Template.Spec.SecurityContext.SeccompProfile = &tekton.SeccompProfile{Type: "RuntimeDefault"}
+	}
+	scheduling, err := getScheduling(c, SchedulingAnnotation)
+	if err != nil {
+		return extensions, err
+	}
+	extensions.Spec.Template.Spec.TopologySpreadConstraints = scheduling.TopologySpreadConstraints
+	return extensions, nil
+}
+
+// applyPodTemplateExtensions patches the component's Deployment so that its pod template has the desired extensions.
+// The operator can't represent them: they're dropped whenever it updates the Deployment and then set again.
+func applyPodTemplateExtensions(c *component.Component) error {
+	desired, err := desiredPodTemplateExtensions(c)
+	if err != nil {
+		return err
+	}
+	if !supportsPodTemplateExtensions() {
+		if len(desired.Spec.Template.Spec.TopologySpreadConstraints) > 0 {
+			return fmt.Errorf("invalid '%s' annotation on component '%s': topologySpreadConstraints require Kubernetes 1.19 or later", SchedulingAnnotation, c.Name)
+		}
+		// the seccomp profile is only set using the annotation
+		return nil
+	}
+
+	data, err := kubeClient().AppsV1().RESTClient().Get().Namespace(c.Namespace).Resource("deployments").Name(c.DeploymentName()).DoRaw()
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	current := podTemplateExtensions{}
+	if err := json.Unmarshal(data, &current); err != nil {
+		return err
+	}
+	if reflect.DeepEqual(current, desired) {
+		return nil
+	}
+	// a JSON merge patch replaces the constraints rather than merging them, removing the ones no longer specified
+	patch, err := json.Marshal(desired)
+	if err != nil {
+		return err
+	}
+	_, err = kubeClient().AppsV1().Deployments(c.Namespace).Patch(c.DeploymentName(), types.MergePatchType, patch)
+	return err
+}
+
+// supportsPodTemplateExtensions returns whether the cluster runs Kubernetes 1.19 or later
+func supportsPodTemplateExtensions() bool {
+	podTemplateExtensionsSupport.Do(func() {
+		info, err := kubeClient().Discovery().ServerVersion()
+		if err != nil {
+			log.Error(err, "couldn't retrieve the version of the cluster, assuming it predates Kubernetes 1.19")
+			return
+		}
+		major, _ := strconv.Atoi(strings.TrimSuffix(info.Major, "+"))
+		minor, _ := strconv.Atoi(strings.TrimSuffix(info.Minor, "+"))
+		podTemplateExtensionsSupport.supported = major > 1 || (major == 1 && minor >= 19)
+	})
+	return podTemplateExtensionsSupport.supported
+}
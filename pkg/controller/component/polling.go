@@ -0,0 +1,203 @@
+package component
+
+import (
+	"context"
+	"fmt"
+	"halkyon.io/api/component/v1beta1"
+	"halkyon.io/operator/pkg/git"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/flowcontrol"
+	"k8s.io/client-go/util/retry"
+	"net/http"
+	"os"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// GitPollRateLimitEnvVar holds the name of the env variable containing the maximum number of git repositories polled
+	// per minute, across all components
+	GitPollRateLimitEnvVar  = "GIT_POLL_RATE_LIMIT"
+	defaultGitPollRateLimit = 60
+	minPollInterval         = time.Minute
+	// pollPeriod is the period at which the Poller checks whether the poll interval of components elapsed
+	pollPeriod = 15 * time.Second
+)
+
+var (
+	log        = logf.Log.WithName("component")
+	gitClient  = &http.Client{Timeout: 10 * time.Second}
+	pollLimit  = newPollRateLimiter()
+	lastPolled = struct {
+		sync.Mutex
+		times map[string]time.Time
+	}{times: make(map[string]time.Time)}
+)
+
+func newPollRateLimiter() flowcontrol.RateLimiter {
+	perMinute := defaultGitPollRateLimit
+	if value, found := os.LookupEnv(GitPollRateLimitEnvVar); found {
+		if limit, err := strconv.Atoi(value); err == nil && limit > 0 {
+			perMinute = limit
+		}
+	}
+	return flowcontrol.NewTokenBucketRateLimiter(float32(perMinute)/60, perMinute)
+}
+
+// pollInterval returns the interval at which the component's git repository is polled for new commits, if polling is
+// enabled using the PollIntervalAnnotation
+func pollInterval(c *v1beta1.Component) (time.Duration, bool, error) {
+	value, found := getAnnotation(c, PollIntervalAnnotation)
+	if !found {
+		return 0, false, nil
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval < minPollInterval {
+		return 0, false, fmt.Errorf("invalid '%s' annotation on component '%s': must be a duration of at least %s", PollIntervalAnnotation, c.Name, minPollInterval)
+	}
+	if scheme := git.Scheme(c.Spec.BuildConfig.URL); scheme != "http" && scheme != "https" && !isSourceArchiveBuild(c) {
+		return 0, false, fmt.Errorf("invalid '%s' annotation on component '%s': only HTTP(S) repositories can be polled, not %s", PollIntervalAnnotation, c.Name, c.Spec.BuildConfig.URL)
+	}
+	return interval, true, nil
+}
+
+// Poller polls the git repositories of the build mode components enabling it using the PollIntervalAnnotation. Polls
+// happen outside of the reconciliation of the components so that slow git servers don't hold it up. It's meant to be
+// added to the operator's manager which starts and stops it.
+type Poller struct {
+	client client.Client
+}
+
+// NewPoller creates a Poller updating the polled components using the specified client
+func NewPoller(c client.Client) *Poller {
+	return &Poller{client: c}
+}
+
+// Start polls the repositories whose poll interval elapsed until the stop channel is closed
+func (p *Poller) Start(stop <-chan struct{}) error {
+	ticker := time.NewTicker(pollPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+			p.poll()
+		}
+	}
+}
+
+func (p *Poller) poll() {
+	components := &v1beta1.ComponentList{}
+	if err := p.client.List(context.TODO(), &client.ListOptions{}, components); err != nil {
+		log.Error(err, "couldn't list the components to poll")
+		return
+	}
+	for i := range components.Items {
+		c := &components.Items[i]
+		if c.Spec.DeploymentMode != v1beta1.BuildDeploymentMode {
+			continue
+		}
+		ref := gitRevision(c)
+		commit, polled := pollGitRef(c)
+		if !polled {
+			continue
+		}
+		if err := p.record(types.NamespacedName{Name: c.Name, Namespace: c.Namespace}, ref, commit); err != nil {
+			log.Error(err, "couldn't record polled commit", "component", c.Name, "namespace", c.Namespace)
+		}
+	}
+}
+
+// record records the commit the specified ref of the component points to, retrying if the component is concurrently
+// modified
+func (p *Poller) record(name types.NamespacedName, ref, commit string) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		c := &v1beta1.Component{}
+		if err := p.client.Get(context.TODO(), name, c); err != nil {
+			if errors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		if gitRevision(c) != ref || !recordPolledCommit(c, commit) {
+			// the component's ref changed since it was polled or its commit didn't
+			return nil
+		}
+		return p.client.Update(context.TODO(), c)
+	})
+}
+
+// pollGitRef resolves the commit the component's git ref currently points to if its poll interval elapsed. Lookups are
+// globally rate-limited: polls which would exceed the limit are postponed to a later round.
+func pollGitRef(c *v1beta1.Component) (string, bool) {
+	interval, enabled, err := pollInterval(c)
+	// components built from a source archive don't build their git repository
+	if err != nil || !enabled || isSourceArchiveBuild(c) {
+		return "", false
+	}
+	key := pollKey(c)
+	lastPolled.Lock()
+	last := lastPolled.times[key]
+	lastPolled.Unlock()
+	if time.Since(last) < interval || !pollLimit.TryAccept() {
+		return "", false
+	}
+	lastPolled.Lock()
+	lastPolled.times[key] = time.Now()
+	lastPolled.Unlock()
+
+	username, password := gitCredentials(c)
+	refs, err := git.LsRemote(gitClient, c.Spec.BuildConfig.URL, username, password)
+	if err != nil {
+		log.Error(err, "couldn't poll git repository", "component", c.Name, "namespace", c.Namespace)
+		return "", false
+	}
+	commit, found := git.ResolveRef(refs, gitRevision(c))
+	if !found {
+		log.Info(fmt.Sprintf("'%s' ref not found in %s", gitRevision(c), c.Spec.BuildConfig.URL), "component", c.Name, "namespace", c.Namespace)
+		return "", false
+	}
+	return commit, true
+}
+
+// recordPolledCommit records the specified commit of the component's ref with the PolledCommitAnnotation, triggering a
+// new build if it changed since the last poll of this ref. Returns whether the component was modified and needs to be
+// updated.
+func recordPolledCommit(c *v1beta1.Component, commit string) bool {
+	polled := gitRevision(c) + "@" + commit
+	previous, found := getAnnotation(c, PolledCommitAnnotation)
+	if previous == polled {
+		return false
+	}
+	if c.Annotations == nil {
+		c.Annotations = make(map[string]string, 3)
+	}
+	c.Annotations[PolledCommitAnnotation] = polled
+	// the first poll of a ref only records its current commit, which the build started when the component was created
+	// or its ref changed checks out. Commits recorded without their ref, by previous versions, are of the current ref.
+	ref, previousCommit := gitRevision(c), previous
+	if i := strings.LastIndex(previous, "@"); i >= 0 {
+		ref, previousCommit = previous[:i], previous[i+1:]
+	}
+	if found && ref == gitRevision(c) && previousCommit != commit {
+		TriggerBuild(c, commit)
+	}
+	return true
+}
+
+// forgetPolls drops when the specified component was last polled, e.g. once it's deleted
+func forgetPolls(c *v1beta1.Component) {
+	lastPolled.Lock()
+	delete(lastPolled.times, pollKey(c))
+	lastPolled.Unlock()
+}
+
+func pollKey(c *v1beta1.Component) string {
+	return c.Namespace + "/" + c.Name
+}
@@ -0,0 +1,107 @@
+package component
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPollInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval string
+		url      string
+		archive  string
+		expected time.Duration
+		fails    bool
+	}{
+		{name: "https repository", interval: "5m", expected: 5 * time.Minute},
+		{name: "http repository", interval: "5m", url: "http://gitea.demo.svc:3000/demo/backend.git", expected: 5 * time.Minute},
+		{name: "too short", interval: "30s", fails: true},
+		{name: "not a duration", interval: "often", fails: true},
+		{name: "ssh repository", interval: "5m", url: "ssh://git@github.com/halkyonio/operator-samples.git", fails: true},
+		{name: "scp-like repository", interval: "5m", url: "git@github.com:halkyonio/operator-samples.git", fails: true},
+		{name: "git repository", interval: "5m", url: "git://github.com/halkyonio/operator-samples.git", fails: true},
+		{
+			name:     "source archive build",
+			interval: "5m",
+			url:      "git@github.com:halkyonio/operator-samples.git",
+			archive:  `{"configMap": "backend-source", "path": "source"}`,
+			expected: 5 * time.Minute,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			annotations := map[string]string{PollIntervalAnnotation: test.interval}
+			if len(test.archive) > 0 {
+				annotations[SourceArchiveAnnotation] = test.archive
+			}
+			c := newTestComponent(annotations)
+			if len(test.url) > 0 {
+				c.Spec.BuildConfig.URL = test.url
+			}
+			interval, enabled, err := pollInterval(c)
+			if test.fails {
+				if err == nil {
+					t.Errorf("expected '%s' interval to be rejected for %s", test.interval, c.Spec.BuildConfig.URL)
+				}
+				return
+			}
+			if err != nil || !enabled || interval != test.expected {
+				t.Errorf("expected %s interval, got %s, %t: %v", test.expected, interval, enabled, err)
+			}
+		})
+	}
+	if _, enabled, err := pollInterval(newTestComponent(nil)); enabled || err != nil {
+		t.Errorf("expected polling to be disabled by default, got %t: %v", enabled, err)
+	}
+}
+
+func TestRecordPolledCommit(t *testing.T) {
+	const first, second = "0123456789abcdef0123456789abcdef01234567", "89abcdef0123456789abcdef0123456789abcdef"
+	c := newTestComponent(nil)
+	if !recordPolledCommit(c, first) {
+		t.Fatalf("expected the first polled commit to be recorded")
+	}
+	if _, triggered := c.Annotations[RebuildAnnotation]; triggered {
+		t.Errorf("expected the first poll not to trigger a build")
+	}
+	if recordPolledCommit(c, first) {
+		t.Errorf("expected an unchanged commit not to modify the component")
+	}
+	if !recordPolledCommit(c, second) {
+		t.Fatalf("expected the new commit to be recorded")
+	}
+	if c.Annotations[PolledCommitAnnotation] != "master@"+second || c.Annotations[RebuildAnnotation] != second {
+		t.Errorf("expected a build of %s to be triggered, got %v", second, c.Annotations)
+	}
+	if triggeredCommit(c) != second {
+		t.Errorf("expected the build to check out %s, got '%s'", second, triggeredCommit(c))
+	}
+
+	// the build of the new ref is started by the change of the build fingerprint
+	c.Spec.BuildConfig.Ref = "develop"
+	if !recordPolledCommit(c, first) {
+		t.Fatalf("expected the commit of the new ref to be recorded")
+	}
+	if c.Annotations[PolledCommitAnnotation] != "develop@"+first || c.Annotations[RebuildAnnotation] != second {
+		t.Errorf("expected the first poll of the new ref not to trigger a build, got %v", c.Annotations)
+	}
+	if recordPolledCommit(c, first) {
+		t.Errorf("expected an unchanged commit of the new ref not to modify the component")
+	}
+}
+
+func TestRecordPolledCommitWithoutRef(t *testing.T) {
+	const first, second = "0123456789abcdef0123456789abcdef01234567", "89abcdef0123456789abcdef0123456789abcdef"
+	c := newTestComponent(map[string]string{PolledCommitAnnotation: first})
+	if !recordPolledCommit(c, first) {
+		t.Fatalf("expected the ref of the polled commit to be recorded")
+	}
+	if _, triggered := c.Annotations[RebuildAnnotation]; triggered || c.Annotations[PolledCommitAnnotation] != "master@"+first {
+		t.Errorf("expected the commit to be recorded along with its ref without triggering a build, got %v", c.Annotations)
+	}
+	c.Annotations[PolledCommitAnnotation] = first
+	if !recordPolledCommit(c, second) || c.Annotations[RebuildAnnotation] != second {
+		t.Errorf("expected a build of %s to be triggered, got %v", second, c.Annotations)
+	}
+}
@@ -0,0 +1,45 @@
+package component
+
+import (
+	component "halkyon.io/api/component/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"os"
+	"strings"
+)
+
+// ImagePullSecretsEnvVar holds the name of the env variable defining, as a comma-separated list, the names of the pull
+// secrets to use for all the images the operator deploys or builds
+const ImagePullSecretsEnvVar = "IMAGE_PULL_SECRETS"
+
+// imagePullSecrets returns the pull secrets configured at the operator level, followed by the specified ones, typically
+// coming from the runtime, and the ones set on the component using the ImagePullSecretsAnnotation
+func imagePullSecrets(c *component.Component, additional ...string) []corev1.LocalObjectReference {
+	operatorSecrets, _ := os.LookupEnv(ImagePullSecretsEnvVar)
+	componentSecrets, _ := getAnnotation(c, ImagePullSecretsAnnotation)
+	names := append(splitNames(operatorSecrets), additional...)
+	names = append(names, splitNames(componentSecrets)...)
+
+	secrets := make([]corev1.LocalObjectReference, 0, len(names))
+	known := make(map[string]bool, len(names))
+	for _, name := range names {
+		if !known[name] {
+			known[name] = true
+			secrets = append(secrets, corev1.LocalObjectReference{Name: name})
+		}
+	}
+	if len(secrets) == 0 {
+		return nil
+	}
+	return secrets
+}
+
+// splitNames splits a comma-separated list of names, ignoring empty ones
+func splitNames(list string) []string {
+	names := make([]string, 0, strings.Count(list, ",")+1)
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); len(name) > 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
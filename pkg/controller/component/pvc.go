@@ -16,7 +16,10 @@ type pvc struct {
 var _ framework.DependentResource = &pvc{}
 
 func newPvc(owner *component.Component) pvc {
-	p := pvc{base: newBaseDependent(&corev1.PersistentVolumeClaim{}, owner)}
+	config := framework.NewConfig(corev1.SchemeGroupVersion.WithKind("PersistentVolumeClaim"))
+	// prebuilt images don't need storage for their sources
+	config.Created = !isImageDeploymentMode(owner)
+	p := pvc{base: newConfiguredBaseDependent(owner, config)}
 	p.NameFn = p.Name
 	return p
 }
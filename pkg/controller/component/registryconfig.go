@@ -0,0 +1,244 @@
+package component
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	component "halkyon.io/api/component/v1beta1"
+	framework "halkyon.io/operator-framework"
+	"halkyon.io/operator/pkg/registry"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const (
+	// RegistryAddressEnvVar, RegistryRepositoryEnvVar, RegistryVerifyTLSEnvVar and RegistryCABundleEnvVar hold the names
+	// of the env variables configuring, for all namespaces, the registry the built images are pushed to: its host, the
+	// template of the repository path of the images, whether its certificate is verified and the PEM encoded certificates
+	// of the authorities to trust when verifying it
+	RegistryAddressEnvVar    = "REGISTRY_ADDRESS"
+	RegistryRepositoryEnvVar = "REGISTRY_REPOSITORY"
+	RegistryVerifyTLSEnvVar  = "REGISTRY_VERIFY_TLS"
+	RegistryCABundleEnvVar   = "REGISTRY_CA_BUNDLE"
+	// RegistryConfigMapName is the name of the ConfigMap overriding, for the components of its namespace, the registry
+	// configuration of the operator using the RegistryAddressKey, RegistryRepositoryKey, RegistryVerifyTLSKey and
+	// RegistryCABundleKey keys
+	RegistryConfigMapName = "halkyon-registry"
+	RegistryAddressKey    = "address"
+	RegistryRepositoryKey = "repository"
+	RegistryVerifyTLSKey  = "verifyTLS"
+	RegistryCABundleKey   = "ca.crt"
+	// defaultRegistryRepository pushes the images of the components to a repository named after them in a namespace
+	// named after theirs
+	defaultRegistryRepository = "{{.Namespace}}/{{.Name}}"
+	// registryCAVolume is the volume holding the CA bundle of the registry in the build pods, mounted to registryCertsPath
+	registryCAVolume  = "registry-ca"
+	registryCertsPath = "/etc/registry-certs"
+)
+
+// registryConfig describes the registry the images of a component are pushed to and deployed from
+type registryConfig struct {
+	address    string
+	repository string
+	verifyTLS  bool
+	caBundle   string
+}
+
+// getRegistryConfig returns the registry configuration of the components of the specified namespace: the operator's,
+// overridden by the values of the RegistryConfigMapName ConfigMap of the namespace, if any
+func getRegistryConfig(namespace string) (registryConfig, error) {
+	overrides := &corev1.ConfigMap{}
+	if err := framework.Helper.Client.Get(context.TODO(), types.NamespacedName{Name: RegistryConfigMapName, Namespace: namespace}, overrides); err != nil {
+		if !errors.IsNotFound(err) {
+			return registryConfig{}, err
+		}
+		overrides.Data = nil
+	}
+	return mergeRegistryConfig(namespace, os.LookupEnv, overrides.Data, defaultRegistryAddress())
+}
+
+// mergeRegistryConfig validates the registry configuration of the operator, read using the specified env lookup
+// function, overridden by the specified values of the RegistryConfigMapName ConfigMap of the namespace, nil if it doesn't
+// exist. The certificate of the registry is verified unless disabled or, since the internal registry of the cluster
+// usually serves plain HTTP or a self-signed certificate, unless the registry is the specified default one and no CA
+// bundle is configured.
+func mergeRegistryConfig(namespace string, lookupEnv func(string) (string, bool), overrides map[string]string, defaultAddress string) (registryConfig, error) {
+	config := registryConfig{address: defaultAddress, repository: defaultRegistryRepository}
+	var verify *bool
+	if address, found := lookupEnv(RegistryAddressEnvVar); found {
+		config.address = address
+	}
+	if repository, found := lookupEnv(RegistryRepositoryEnvVar); found {
+		config.repository = repository
+	}
+	if value, found := lookupEnv(RegistryVerifyTLSEnvVar); found {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return registryConfig{}, fmt.Errorf("invalid '%s' env variable: must be a boolean", RegistryVerifyTLSEnvVar)
+		}
+		verify = &parsed
+	}
+	config.caBundle, _ = lookupEnv(RegistryCABundleEnvVar)
+
+	if address, found := overrides[RegistryAddressKey]; found {
+		config.address = address
+	}
+	if repository, found := overrides[RegistryRepositoryKey]; found {
+		config.repository = repository
+	}
+	if value, found := overrides[RegistryVerifyTLSKey]; found {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return registryConfig{}, fmt.Errorf("invalid '%s' key of '%s' ConfigMap: must be a boolean", RegistryVerifyTLSKey, RegistryConfigMapName)
+		}
+		verify = &parsed
+	}
+	if bundle, found := overrides[RegistryCABundleKey]; found {
+		config.caBundle = bundle
+	}
+
+	config.address = strings.TrimSuffix(config.address, "/")
+	if len(config.address) == 0 {
+		return registryConfig{}, fmt.Errorf("registry address of namespace '%s' cannot be empty", namespace)
+	}
+	if len(config.repository) == 0 {
+		return registryConfig{}, fmt.Errorf("registry repository template of namespace '%s' cannot be empty", namespace)
+	}
+	if _, err := template.New("repository").Parse(config.repository); err != nil {
+		return registryConfig{}, fmt.Errorf("invalid registry repository template of namespace '%s': %v", namespace, err)
+	}
+	if verify != nil {
+		config.verifyTLS = *verify
+	} else {
+		config.verifyTLS = config.address != defaultAddress || len(config.caBundle) > 0
+	}
+	return config, nil
+}
+
+// defaultRegistryAddress returns the address of the internal registry of the cluster
+func defaultRegistryAddress() string {
+	if framework.IsTargetClusterRunningOpenShift() {
+		if framework.OpenShiftVersion() == 4 {
+			return "image-registry.openshift-image-registry.svc:5000"
+		}
+		return "docker-registry.default.svc:5000"
+	}
+	return "kube-registry.kube-system.svc:5000"
+}
+
+// imageURL returns the reference of the image of the specified component in the configured registry, the repository
+// path being computed from the repository template using the Namespace and Name of the component
+func (in registryConfig) imageURL(c *component.Component) (string, error) {
+	repository := &bytes.Buffer{}
+	tmpl, err := template.New("repository").Option("missingkey=error").Parse(in.repository)
+	if err == nil {
+		err = tmpl.Execute(repository, struct{ Namespace, Name string }{c.Namespace, c.Name})
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid registry repository template '%s': %v", in.repository, err)
+	}
+	image := in.address + "/" + strings.Trim(repository.String(), "/")
+	if _, err := registry.ParseReference(image); err != nil {
+		return "", fmt.Errorf("invalid image reference '%s' computed for component '%s': %v", image, c.Name, err)
+	}
+	// the builds tag the image themselves
+	if strings.Contains(image, "@") || strings.LastIndex(image, ":") > strings.LastIndex(image, "/") {
+		return "", fmt.Errorf("registry repository template '%s' cannot specify a tag or digest", in.repository)
+	}
+	return image, nil
+}
+
+// tls returns how the certificate of the registry is verified when resolving the digests of the images
+func (in registryConfig) tls() registry.TLS {
+	return registry.TLS{Insecure: !in.verifyTLS, CABundle: in.caBundle}
+}
+
+// checkRegistryConfig validates the registry configuration of the component's namespace along with the reference of its
+// image
+func checkRegistryConfig(c *component.Component) error {
+	config, err := getRegistryConfig(c.Namespace)
+	if err != nil {
+		return err
+	}
+	_, err = config.imageURL(c)
+	return err
+}
+
+// registryTLS returns how the certificate of the registry the component's image is pushed to is verified
+func registryTLS(c *component.Component) (registry.TLS, error) {
+	config, err := getRegistryConfig(c.Namespace)
+	if err != nil {
+		return registry.TLS{}, err
+	}
+	return config.tls(), nil
+}
+
+// RegistryCAName returns the name of the ConfigMap holding the CA bundle trusted by the builds of the specified component
+func RegistryCAName(c *component.Component) string {
+	return c.Name + "-registry-ca"
+}
+
+// registryCA holds the CA bundle of the registry in a ConfigMap mounted by the builds, as ConfigMaps of other namespaces
+// cannot be mounted. The ConfigMap is always created, empty if no bundle is configured, so that the Task doesn't depend
+// on the configuration.
+type registryCA struct {
+	base
+}
+
+var _ framework.DependentResource = &registryCA{}
+
+func newRegistryCA(owner *component.Component) registryCA {
+	config := framework.NewConfig(corev1.SchemeGroupVersion.WithKind("ConfigMap"))
+	config.Created = component.BuildDeploymentMode == owner.Spec.DeploymentMode
+	config.Updated = config.Created
+	r := registryCA{base: newConfiguredBaseDependent(owner, config)}
+	r.NameFn = r.Name
+	return r
+}
+
+func (res registryCA) Build(empty bool) (runtime.Object, error) {
+	configMap := &corev1.ConfigMap{}
+	if !empty {
+		c := res.ownerAsComponent()
+		config, err := getRegistryConfig(c.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		configMap.ObjectMeta = metav1.ObjectMeta{
+			Name:      res.Name(),
+			Namespace: c.Namespace,
+			Labels:    getBuildLabels(c.Name),
+		}
+		configMap.Data = map[string]string{}
+		if len(config.caBundle) > 0 {
+			configMap.Data[RegistryCABundleKey] = config.caBundle
+		}
+	}
+	return configMap, nil
+}
+
+// Update replaces the CA bundle when the registry configuration changed
+func (res registryCA) Update(toUpdate runtime.Object) (bool, runtime.Object, error) {
+	current := toUpdate.(*corev1.ConfigMap)
+	built, err := res.Build(false)
+	if err != nil {
+		return false, toUpdate, err
+	}
+	desired := built.(*corev1.ConfigMap)
+	if current.Data[RegistryCABundleKey] == desired.Data[RegistryCABundleKey] {
+		return false, current, nil
+	}
+	current.Data = desired.Data
+	return true, current, nil
+}
+
+func (res registryCA) Name() string {
+	return RegistryCAName(res.ownerAsComponent())
+}
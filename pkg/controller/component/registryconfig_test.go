@@ -0,0 +1,117 @@
+package component
+
+import (
+	"testing"
+)
+
+const testRegistryAddress = "kube-registry.kube-system.svc:5000"
+
+func lookup(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, found := env[name]
+		return value, found
+	}
+}
+
+func TestMergeRegistryConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       map[string]string
+		overrides map[string]string
+		expected  registryConfig
+	}{
+		{
+			name:     "defaults",
+			expected: registryConfig{address: testRegistryAddress, repository: defaultRegistryRepository},
+		},
+		{
+			name: "operator configuration",
+			env: map[string]string{
+				RegistryAddressEnvVar:    "quay.io/",
+				RegistryRepositoryEnvVar: "halkyon/{{.Name}}",
+			},
+			expected: registryConfig{address: "quay.io", repository: "halkyon/{{.Name}}", verifyTLS: true},
+		},
+		{
+			name: "namespace overrides",
+			env: map[string]string{
+				RegistryAddressEnvVar:    "quay.io",
+				RegistryRepositoryEnvVar: "halkyon/{{.Name}}",
+				RegistryVerifyTLSEnvVar:  "true",
+			},
+			overrides: map[string]string{
+				RegistryAddressKey:   "registry.demo.svc:5000",
+				RegistryVerifyTLSKey: "false",
+			},
+			expected: registryConfig{address: "registry.demo.svc:5000", repository: "halkyon/{{.Name}}"},
+		},
+		{
+			name:      "internal registry with a CA bundle",
+			overrides: map[string]string{RegistryCABundleKey: "bundle"},
+			expected:  registryConfig{address: testRegistryAddress, repository: defaultRegistryRepository, verifyTLS: true, caBundle: "bundle"},
+		},
+		{
+			name:      "namespace CA bundle",
+			env:       map[string]string{RegistryCABundleEnvVar: "operator bundle"},
+			overrides: map[string]string{RegistryCABundleKey: "namespace bundle"},
+			expected:  registryConfig{address: testRegistryAddress, repository: defaultRegistryRepository, verifyTLS: true, caBundle: "namespace bundle"},
+		},
+		{
+			name:      "unverified CA bundle",
+			env:       map[string]string{RegistryVerifyTLSEnvVar: "false"},
+			overrides: map[string]string{RegistryCABundleKey: "bundle"},
+			expected:  registryConfig{address: testRegistryAddress, repository: defaultRegistryRepository, caBundle: "bundle"},
+		},
+		{
+			name:     "verified internal registry",
+			env:      map[string]string{RegistryVerifyTLSEnvVar: "true"},
+			expected: registryConfig{address: testRegistryAddress, repository: defaultRegistryRepository, verifyTLS: true},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config, err := mergeRegistryConfig("demo", lookup(test.env), test.overrides, testRegistryAddress)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if config != test.expected {
+				t.Errorf("expected %+v, got %+v", test.expected, config)
+			}
+		})
+	}
+}
+
+func TestMergeInvalidRegistryConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       map[string]string
+		overrides map[string]string
+	}{
+		{name: "invalid verification", env: map[string]string{RegistryVerifyTLSEnvVar: "maybe"}},
+		{name: "invalid namespace verification", overrides: map[string]string{RegistryVerifyTLSKey: "maybe"}},
+		{name: "empty address", overrides: map[string]string{RegistryAddressKey: "/"}},
+		{name: "empty repository", env: map[string]string{RegistryRepositoryEnvVar: ""}},
+		{name: "invalid repository", overrides: map[string]string{RegistryRepositoryKey: "{{.Namespace"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if config, err := mergeRegistryConfig("demo", lookup(test.env), test.overrides, testRegistryAddress); err == nil {
+				t.Errorf("expected the configuration to be rejected, got %+v", config)
+			}
+		})
+	}
+}
+
+func TestRegistryImageURL(t *testing.T) {
+	c := newTestComponent(nil)
+	config := registryConfig{address: "quay.io", repository: "halkyon/{{.Namespace}}-{{.Name}}"}
+	if image, err := config.imageURL(c); err != nil || image != "quay.io/halkyon/demo-backend" {
+		t.Errorf("expected quay.io/halkyon/demo-backend image, got %s: %v", image, err)
+	}
+	for _, repository := range []string{"{{.Name}}:latest", "{{.Name}}@sha256:1234", "{{.Image}}"} {
+		config.repository = repository
+		if image, err := config.imageURL(c); err == nil {
+			t.Errorf("expected '%s' repository template to be rejected, got %s", repository, image)
+		}
+	}
+}
@@ -21,10 +21,15 @@ func (res role) Build(empty bool) (runtime.Object, error) {
 	}
 	r := ser.(*authorizv1.Role)
 	if !empty {
+		// only grant what's needed to push the built image to the internal OpenShift registry
 		r.Rules = append(r.Rules, authorizv1.PolicyRule{
 			APIGroups: []string{"image.openshift.io"},
-			Resources: []string{"imagestreams", "imagestreams/layers"},
-			Verbs:     []string{"*"},
+			Resources: []string{"imagestreams"},
+			Verbs:     []string{"get", "list", "watch", "create", "update", "patch"},
+		}, authorizv1.PolicyRule{
+			APIGroups: []string{"image.openshift.io"},
+			Resources: []string{"imagestreams/layers"},
+			Verbs:     []string{"get", "update"},
 		})
 	}
 
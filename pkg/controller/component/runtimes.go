@@ -1,6 +1,7 @@
 package component
 
 import (
+	"encoding/json"
 	"fmt"
 	"halkyon.io/api/component/v1beta1"
 	"halkyon.io/api/runtime/clientset/versioned"
@@ -8,12 +9,15 @@ import (
 	halkyon "halkyon.io/api/v1beta1"
 	framework "halkyon.io/operator-framework"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"os"
 	"strings"
 )
 
 const (
 	supervisorContainerName = "copy-supervisord"
 	supervisorImageId       = "supervisord"
+	// SupervisorImageEnvVar holds the name of the env variable overriding the supervisord image, e.g. to use a mirror
+	SupervisorImageEnvVar = "SUPERVISOR_IMAGE"
 )
 
 var runtimesClient v1beta12.RuntimeInterface
@@ -21,11 +25,18 @@ var runtimesClient v1beta12.RuntimeInterface
 type Runtime struct {
 	RegistryRef string
 	defaultEnv  map[string]string
+	pullSecrets []string
+	builder     string
+	s2iBuilder  string
+	buildEnv    map[string]string
 }
 
 func getImageInfo(component *v1beta1.Component) (Runtime, error) {
 	spec := component.Spec
 	if spec.Runtime == supervisorImageId {
+		if image, found := os.LookupEnv(SupervisorImageEnvVar); found {
+			return Runtime{RegistryRef: image}, nil
+		}
 		return Runtime{RegistryRef: "quay.io/halkyonio/supervisord"}, nil
 	}
 
@@ -46,6 +57,16 @@ func getImageInfo(component *v1beta1.Component) (Runtime, error) {
 			version := item.Spec.Version
 			if version == spec.Version {
 				runtime := Runtime{RegistryRef: item.Spec.Image}
+				if secrets, found := item.Annotations[ImagePullSecretsAnnotation]; found {
+					runtime.pullSecrets = splitNames(secrets)
+				}
+				runtime.builder = item.Annotations[BuildpacksBuilderAnnotation]
+				runtime.s2iBuilder = item.Annotations[S2IBuilderAnnotation]
+				if env, found := item.Annotations[BuildEnvAnnotation]; found {
+					if err := json.Unmarshal([]byte(env), &runtime.buildEnv); err != nil {
+						return Runtime{}, fmt.Errorf("invalid '%s' annotation on '%s' runtime: %s", BuildEnvAnnotation, item.Name, err.Error())
+					}
+				}
 
 				envMap := make(map[string]string, len(item.Spec.Envs)+1)
 				if len(item.Spec.ExecutablePattern) > 0 {
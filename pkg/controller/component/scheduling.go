@@ -0,0 +1,42 @@
+package component
+
+import (
+	"fmt"
+	component "halkyon.io/api/component/v1beta1"
+	"halkyon.io/operator/pkg/tekton"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Scheduling holds the constraints on where pods can be scheduled, as specified using the SchedulingAnnotation for the
+// component's pods or the BuildSchedulingAnnotation for its build pods.
+type Scheduling struct {
+	NodeSelector map[string]string   `json:"nodeSelector,omitempty"`
+	Tolerations  []corev1.Toleration `json:"tolerations,omitempty"`
+	Affinity     *corev1.Affinity    `json:"affinity,omitempty"`
+	// TopologySpreadConstraints aren't part of the Kubernetes API the operator is built against: they're set by patching
+	// the component's Deployment, see applyPodTemplateExtensions, and passed to Tekton for the build pods
+	TopologySpreadConstraints []tekton.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+}
+
+func getScheduling(c *component.Component, annotation string) (Scheduling, error) {
+	scheduling := Scheduling{}
+	if _, err := decodeAnnotation(c, annotation, &scheduling); err != nil {
+		return scheduling, err
+	}
+	for _, constraint := range scheduling.TopologySpreadConstraints {
+		if constraint.MaxSkew < 1 || len(constraint.TopologyKey) == 0 ||
+			(constraint.WhenUnsatisfiable != "DoNotSchedule" && constraint.WhenUnsatisfiable != "ScheduleAnyway") {
+			return scheduling, fmt.Errorf("invalid '%s' annotation on component '%s': topologySpreadConstraints must specify a "+
+				"topologyKey, a maxSkew of at least 1 and DoNotSchedule or ScheduleAnyway as whenUnsatisfiable", annotation, c.Name)
+		}
+	}
+	return scheduling, nil
+}
+
+// applyTo sets the scheduling constraints on the specified pod spec, except for the topology spread constraints which it
+// can't represent
+func (in Scheduling) applyTo(spec *corev1.PodSpec) {
+	spec.NodeSelector = in.NodeSelector
+	spec.Tolerations = in.Tolerations
+	spec.Affinity = in.Affinity
+}
@@ -0,0 +1,60 @@
+package component
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScheduling(t *testing.T) {
+	c := newTestComponent(map[string]string{SchedulingAnnotation: `{"nodeSelector": {"disk": "ssd"}, "topologySpreadConstraints": [
+		{"maxSkew": 1, "topologyKey": "topology.kubernetes.io/zone", "whenUnsatisfiable": "ScheduleAnyway", "labelSelector": {"matchLabels": {"app": "backend"}}}]}`})
+	scheduling, err := getScheduling(c, SchedulingAnnotation)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if scheduling.NodeSelector["disk"] != "ssd" || len(scheduling.TopologySpreadConstraints) != 1 {
+		t.Fatalf("expected the node selector and topology spread constraint to be decoded, got %+v", scheduling)
+	}
+	constraint := scheduling.TopologySpreadConstraints[0]
+	if constraint.MaxSkew != 1 || constraint.TopologyKey != "topology.kubernetes.io/zone" || constraint.LabelSelector.MatchLabels["app"] != "backend" {
+		t.Errorf("unexpected topology spread constraint: %+v", constraint)
+	}
+
+	extensions, err := desiredPodTemplateExtensions(c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(extensions.Spec.Template.Spec.TopologySpreadConstraints, scheduling.TopologySpreadConstraints) {
+		t.Errorf("expected the topology spread constraints to be set on the pod template, got %+v", extensions.Spec.Template.Spec)
+	}
+	if profile := extensions.Spec.Template.Spec.SecurityContext.SeccompProfile; profile == nil || profile.Type != "RuntimeDefault" {
+		t.Errorf("expected the restricted pods to use the runtime's default seccomp profile, got %+v", profile)
+	}
+}
+
+func TestInvalidScheduling(t *testing.T) {
+	tests := map[string]string{
+		"no topology key":            `{"topologySpreadConstraints": [{"maxSkew": 1, "whenUnsatisfiable": "DoNotSchedule"}]}`,
+		"no skew":                    `{"topologySpreadConstraints": [{"maxSkew": 0, "topologyKey": "kubernetes.io/hostname", "whenUnsatisfiable": "DoNotSchedule"}]}`,
+		"unknown unsatisfiable mode": `{"topologySpreadConstraints": [{"maxSkew": 1, "topologyKey": "kubernetes.io/hostname", "whenUnsatisfiable": "Ignore"}]}`,
+		"unknown field":              `{"nodeSelectors": {"disk": "ssd"}}`,
+	}
+	for name, value := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := newTestComponent(map[string]string{BuildSchedulingAnnotation: value})
+			if scheduling, err := getScheduling(c, BuildSchedulingAnnotation); err == nil {
+				t.Errorf("expected '%s' to be rejected, got %+v", value, scheduling)
+			}
+		})
+	}
+}
+
+func TestUnrestrictedPodTemplateExtensions(t *testing.T) {
+	extensions, err := desiredPodTemplateExtensions(newTestComponent(map[string]string{PodSecurityAnnotation: UnrestrictedPodSecurity}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(extensions, podTemplateExtensions{}) {
+		t.Errorf("expected no extension, got %+v", extensions)
+	}
+}
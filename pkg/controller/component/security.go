@@ -0,0 +1,126 @@
+package component
+
+import (
+	"fmt"
+	component "halkyon.io/api/component/v1beta1"
+	"halkyon.io/operator-framework/util"
+	"halkyon.io/operator/pkg/tekton"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// RestrictedPodSecurity runs the component's containers as non-root, without privilege escalation nor capabilities.
+	// The images must therefore run as a non-root user, which supervisord used in dev mode doesn't.
+	RestrictedPodSecurity = "restricted"
+	// UnrestrictedPodSecurity leaves the security context of the component's containers to the image and cluster defaults
+	UnrestrictedPodSecurity = "unrestricted"
+	seccompPodAnnotation    = "seccomp.security.alpha.kubernetes.io/pod"
+	// buildUserId is the non-root user running unprivileged builds, as defined by the buildah image
+	buildUserId = int64(1000)
+	// PodSecurityLevelAttributeKey is the condition attribute recording the highest Pod Security level the build pods
+	// comply with: baseline for unprivileged builds, privileged otherwise
+	PodSecurityLevelAttributeKey = "PodSecurityLevel"
+)
+
+// podSecurity returns the pod security of the component: restricted unless it opted out, or in dev mode where
+// supervisord runs as root
+func podSecurity(c *component.Component) (string, error) {
+	security, found := getAnnotation(c, PodSecurityAnnotation)
+	if !found {
+		if isDevDeploymentMode(c) {
+			return UnrestrictedPodSecurity, nil
+		}
+		return RestrictedPodSecurity, nil
+	}
+	if security != RestrictedPodSecurity && security != UnrestrictedPodSecurity {
+		return "", fmt.Errorf("unknown '%s' pod security, must be one of: %s, %s", security, RestrictedPodSecurity, UnrestrictedPodSecurity)
+	}
+	if security == RestrictedPodSecurity && isDevDeploymentMode(c) {
+		return "", fmt.Errorf("'%s' pod security isn't supported by component '%s' in %s mode: supervisord runs as root", security, c.Name, component.DevDeploymentMode)
+	}
+	return security, nil
+}
+
+func isUnprivilegedBuild(c *component.Component) bool {
+	unprivileged, _ := getAnnotation(c, UnprivilegedBuildAnnotation)
+	return unprivileged == "true"
+}
+
+// buildPodSecurityLevel returns the highest Pod Security level the build pods of the component comply with
+func buildPodSecurityLevel(c *component.Component) string {
+	if isUnprivilegedBuild(c) {
+		return "baseline"
+	}
+	return "privileged"
+}
+
+// applyPodSecurity hardens the security context of the specified pod template unless the component opted out. Containers
+// already defining their own security context, such as user-defined sidecars, are left untouched.
+func applyPodSecurity(c *component.Component, template *corev1.PodTemplateSpec) error {
+	security, err := podSecurity(c)
+	if err != nil {
+		return err
+	}
+	if security != RestrictedPodSecurity {
+		return nil
+	}
+
+	template.Spec.SecurityContext = &corev1.PodSecurityContext{RunAsNonRoot: util.NewTrue()}
+	if template.Annotations == nil {
+		template.Annotations = make(map[string]string, 1)
+	}
+	template.Annotations[seccompPodAnnotation] = "runtime/default"
+	for i := range template.Spec.InitContainers {
+		if template.Spec.InitContainers[i].SecurityContext == nil {
+			template.Spec.InitContainers[i].SecurityContext = restrictedSecurityContext()
+		}
+	}
+	for i := range template.Spec.Containers {
+		if template.Spec.Containers[i].SecurityContext == nil {
+			template.Spec.Containers[i].SecurityContext = restrictedSecurityContext()
+		}
+	}
+	return nil
+}
+
+func restrictedSecurityContext() *corev1.SecurityContext {
+	allowPrivilegeEscalation := false
+	return &corev1.SecurityContext{
+		RunAsNonRoot:             util.NewTrue(),
+		AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+		Capabilities: &corev1.Capabilities{
+			Drop: []corev1.Capability{"ALL"},
+		},
+	}
+}
+
+// unprivilegedBuildSecurityContext returns the security context of the steps of unprivileged builds, running as the
+// non-root user defined by the buildah image. Rootless buildah maps the users of the built image using the setuid
+// newuidmap and newgidmap helpers: its steps are therefore allowed to escalate privileges and keep the SETUID and SETGID
+// capabilities. Unprivileged builds thus comply with the baseline Pod Security level, not with the restricted one.
+func unprivilegedBuildSecurityContext(buildah bool) *corev1.SecurityContext {
+	context := restrictedSecurityContext()
+	user := buildUserId
+	context.RunAsUser = &user
+	if buildah {
+		allowPrivilegeEscalation := true
+		context.AllowPrivilegeEscalation = &allowPrivilegeEscalation
+		context.Capabilities.Add = []corev1.Capability{"SETUID", "SETGID"}
+	}
+	return context
+}
+
+// unprivilegedBuildPodSecurityContext returns the security context of the pods of unprivileged builds: the build user's
+// group owns the volumes, e.g. the build cache, so that the steps can write to them, and the runtime's default seccomp
+// profile is used
+func unprivilegedBuildPodSecurityContext() *tekton.PodSecurityContext {
+	user := buildUserId
+	return &tekton.PodSecurityContext{
+		PodSecurityContext: corev1.PodSecurityContext{
+			RunAsNonRoot: util.NewTrue(),
+			RunAsUser:    &user,
+			FSGroup:      &user,
+		},
+		SeccompProfile: &tekton.SeccompProfile{Type: "RuntimeDefault"},
+	}
+}
@@ -0,0 +1,76 @@
+package component
+
+import (
+	"halkyon.io/api/component/v1beta1"
+	"testing"
+)
+
+func TestPodSecurity(t *testing.T) {
+	tests := []struct {
+		name     string
+		dev      bool
+		security string
+		expected string
+	}{
+		{name: "build mode default", expected: RestrictedPodSecurity},
+		{name: "dev mode default", dev: true, expected: UnrestrictedPodSecurity},
+		{name: "opt-out", security: UnrestrictedPodSecurity, expected: UnrestrictedPodSecurity},
+		{name: "restricted", security: RestrictedPodSecurity, expected: RestrictedPodSecurity},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			annotations := map[string]string{}
+			if len(test.security) > 0 {
+				annotations[PodSecurityAnnotation] = test.security
+			}
+			c := newTestComponent(annotations)
+			if test.dev {
+				c.Spec.DeploymentMode = v1beta1.DevDeploymentMode
+			}
+			if security, err := podSecurity(c); err != nil || security != test.expected {
+				t.Errorf("expected %s pod security, got %s: %v", test.expected, security, err)
+			}
+		})
+	}
+
+	c := newTestComponent(map[string]string{PodSecurityAnnotation: "privileged"})
+	if security, err := podSecurity(c); err == nil {
+		t.Errorf("expected unknown pod security to be rejected, got %s", security)
+	}
+	c = newTestComponent(map[string]string{PodSecurityAnnotation: RestrictedPodSecurity})
+	c.Spec.DeploymentMode = v1beta1.DevDeploymentMode
+	if security, err := podSecurity(c); err == nil {
+		t.Errorf("expected restricted pod security to be rejected in dev mode, got %s", security)
+	}
+}
+
+func TestPodConfigHash(t *testing.T) {
+	c := newTestComponent(nil)
+	restricted := podConfigHash(c)
+	if len(restricted) == 0 {
+		t.Errorf("expected the components restricted by default to be rolled out")
+	}
+	c.Annotations = map[string]string{PodSecurityAnnotation: RestrictedPodSecurity}
+	if hash := podConfigHash(c); hash != restricted {
+		t.Errorf("expected explicitly restricted pod security not to roll the component out")
+	}
+	c.Annotations = map[string]string{PodSecurityAnnotation: UnrestrictedPodSecurity}
+	if hash := podConfigHash(c); len(hash) > 0 {
+		t.Errorf("expected unrestricted components not to be rolled out, got %s hash", hash)
+	}
+	c.Annotations = nil
+	c.Spec.DeploymentMode = v1beta1.DevDeploymentMode
+	if hash := podConfigHash(c); len(hash) > 0 {
+		t.Errorf("expected dev mode components not to be rolled out, got %s hash", hash)
+	}
+}
+
+func TestBuildPodSecurityLevel(t *testing.T) {
+	if level := buildPodSecurityLevel(newTestComponent(nil)); level != "privileged" {
+		t.Errorf("expected privileged builds by default, got %s", level)
+	}
+	c := newTestComponent(map[string]string{UnprivilegedBuildAnnotation: "true"})
+	if level := buildPodSecurityLevel(c); level != "baseline" {
+		t.Errorf("expected unprivileged builds to comply with the baseline level, got %s", level)
+	}
+}
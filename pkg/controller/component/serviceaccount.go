@@ -4,6 +4,7 @@ import (
 	v1beta12 "halkyon.io/api/component/v1beta1"
 	framework "halkyon.io/operator-framework"
 	"k8s.io/apimachinery/pkg/runtime"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -16,7 +17,9 @@ type serviceAccount struct {
 var _ framework.DependentResource = &serviceAccount{}
 
 func newServiceAccount(owner *v1beta12.Component) serviceAccount {
-	s := serviceAccount{base: newBaseDependent(&corev1.ServiceAccount{}, owner)}
+	config := framework.NewConfig(corev1.SchemeGroupVersion.WithKind("ServiceAccount"))
+	config.Updated = true
+	s := serviceAccount{base: newConfiguredBaseDependent(owner, config)}
 	s.NameFn = s.Name
 	return s
 }
@@ -32,10 +35,110 @@ func (res serviceAccount) Build(empty bool) (runtime.Object, error) {
 			Namespace: c.Namespace,
 			Labels:    ls,
 		}
+		// link the pull secrets and git credentials so that Tekton makes them available to the build steps to pull and
+		// push images and to the clone step
+		pullSecrets := imagePullSecrets(c)
+		sa.ImagePullSecrets = pullSecrets
+		linked := linkedSecrets(c, pullSecrets)
+		for _, name := range linked {
+			sa.Secrets = append(sa.Secrets, corev1.ObjectReference{Name: name})
+		}
+		sa.Annotations = map[string]string{LinkedSecretsAnnotation: strings.Join(linked, ",")}
 	}
 	return sa, nil
 }
 
+// linkedSecrets returns the names of the Secrets the operator links to the service account: the specified pull secrets
+// of the component and its git credentials
+func linkedSecrets(c *v1beta12.Component, pullSecrets []corev1.LocalObjectReference) []string {
+	names := make([]string, 0, len(pullSecrets)+1)
+	for _, secret := range pullSecrets {
+		names = append(names, secret.Name)
+	}
+	if name, found := gitSecretName(c); found && !contains(names, name) {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Update links the component's pull secrets and git credentials to the service account and unlinks the ones it previously linked which the
+// component doesn't use anymore, keeping the other secrets linked as they might have been added by the cluster, e.g. the
+// registry secrets OpenShift links to every service account
+func (res serviceAccount) Update(toUpdate runtime.Object) (bool, runtime.Object, error) {
+	sa := toUpdate.(*corev1.ServiceAccount)
+	updated := false
+	c := res.ownerAsComponent()
+	pullSecrets := imagePullSecrets(c)
+	linked := linkedSecrets(c, pullSecrets)
+	for _, name := range splitNames(sa.Annotations[LinkedSecretsAnnotation]) {
+		if !contains(linked, name) {
+			updated = unlinkSecret(sa, name) || updated
+		}
+	}
+	for _, secret := range pullSecrets {
+		if !containsSecretRef(sa.ImagePullSecrets, secret.Name) {
+			sa.ImagePullSecrets = append(sa.ImagePullSecrets, secret)
+			updated = true
+		}
+	}
+	for _, name := range linked {
+		updated = linkSecret(sa, name) || updated
+	}
+	if value := strings.Join(linked, ","); sa.Annotations[LinkedSecretsAnnotation] != value {
+		if sa.Annotations == nil {
+			sa.Annotations = make(map[string]string, 1)
+		}
+		sa.Annotations[LinkedSecretsAnnotation] = value
+		updated = true
+	}
+	return updated, sa, nil
+}
+
+// linkSecret adds the specified Secret to the secrets of the service account, returning whether it wasn't linked yet
+func linkSecret(sa *corev1.ServiceAccount, name string) bool {
+	for _, ref := range sa.Secrets {
+		if ref.Name == name {
+			return false
+		}
+	}
+	sa.Secrets = append(sa.Secrets, corev1.ObjectReference{Name: name})
+	return true
+}
+
+// unlinkSecret removes the specified Secret from the secrets and pull secrets of the service account, returning whether
+// it was linked
+func unlinkSecret(sa *corev1.ServiceAccount, name string) bool {
+	unlinked := false
+	secrets := sa.Secrets[:0]
+	for _, ref := range sa.Secrets {
+		if ref.Name == name {
+			unlinked = true
+			continue
+		}
+		secrets = append(secrets, ref)
+	}
+	sa.Secrets = secrets
+	pullSecrets := sa.ImagePullSecrets[:0]
+	for _, ref := range sa.ImagePullSecrets {
+		if ref.Name == name {
+			unlinked = true
+			continue
+		}
+		pullSecrets = append(pullSecrets, ref)
+	}
+	sa.ImagePullSecrets = pullSecrets
+	return unlinked
+}
+
+func containsSecretRef(refs []corev1.LocalObjectReference, name string) bool {
+	for _, ref := range refs {
+		if ref.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
 func (res serviceAccount) Name() string {
 	return ServiceAccountName(res.Owner())
 }
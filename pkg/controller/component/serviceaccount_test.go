@@ -0,0 +1,105 @@
+package component
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+	"testing"
+)
+
+// newTestServiceAccount returns a service account with the specified secrets linked, also as pull secrets
+func newTestServiceAccount(linked string, secrets ...string) *corev1.ServiceAccount {
+	sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "backend-build"}}
+	if len(linked) > 0 {
+		sa.Annotations = map[string]string{LinkedSecretsAnnotation: linked}
+	}
+	for _, name := range secrets {
+		sa.Secrets = append(sa.Secrets, corev1.ObjectReference{Name: name})
+		sa.ImagePullSecrets = append(sa.ImagePullSecrets, corev1.LocalObjectReference{Name: name})
+	}
+	return sa
+}
+
+func secretNames(sa *corev1.ServiceAccount) ([]string, []string) {
+	var secrets, pullSecrets []string
+	for _, ref := range sa.Secrets {
+		secrets = append(secrets, ref.Name)
+	}
+	for _, ref := range sa.ImagePullSecrets {
+		pullSecrets = append(pullSecrets, ref.Name)
+	}
+	return secrets, pullSecrets
+}
+
+func TestServiceAccountUpdate(t *testing.T) {
+	tests := []struct {
+		name        string
+		sa          *corev1.ServiceAccount
+		secrets     []string
+		gitSecret   string
+		pullSecrets []string
+		updated     bool
+		linked      string
+	}{
+		{
+			name:        "unchanged",
+			sa:          newTestServiceAccount("quay", "builder-dockercfg", "quay"),
+			secrets:     []string{"builder-dockercfg", "quay"},
+			pullSecrets: []string{"builder-dockercfg", "quay"},
+			linked:      "quay",
+		},
+		{
+			name:        "replaced pull secret",
+			sa:          newTestServiceAccount("dockerhub", "builder-dockercfg", "dockerhub"),
+			secrets:     []string{"builder-dockercfg", "quay"},
+			pullSecrets: []string{"builder-dockercfg", "quay"},
+			updated:     true,
+			linked:      "quay",
+		},
+		{
+			name:        "replaced git credentials",
+			sa:          newTestServiceAccount("quay,github", "builder-dockercfg", "quay", "github"),
+			gitSecret:   "gitlab",
+			secrets:     []string{"builder-dockercfg", "quay", "gitlab"},
+			pullSecrets: []string{"builder-dockercfg", "quay"},
+			updated:     true,
+			linked:      "quay,gitlab",
+		},
+		{
+			name:        "removed git credentials",
+			sa:          newTestServiceAccount("quay,github", "builder-dockercfg", "quay", "github"),
+			secrets:     []string{"builder-dockercfg", "quay"},
+			pullSecrets: []string{"builder-dockercfg", "quay"},
+			updated:     true,
+			linked:      "quay",
+		},
+		{
+			name:        "linked before being recorded",
+			sa:          newTestServiceAccount("", "builder-dockercfg", "dockerhub"),
+			secrets:     []string{"builder-dockercfg", "dockerhub", "quay"},
+			pullSecrets: []string{"builder-dockercfg", "dockerhub", "quay"},
+			updated:     true,
+			linked:      "quay",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestComponent(map[string]string{ImagePullSecretsAnnotation: "quay", GitSecretAnnotation: test.gitSecret})
+			updated, object, err := newServiceAccount(c).Update(test.sa)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if updated != test.updated {
+				t.Errorf("expected the service account to be updated: %t", test.updated)
+			}
+			sa := object.(*corev1.ServiceAccount)
+			secrets, pullSecrets := secretNames(sa)
+			if !reflect.DeepEqual(secrets, test.secrets) || !reflect.DeepEqual(pullSecrets, test.pullSecrets) {
+				t.Errorf("expected %v secrets and %v pull secrets, got %v and %v", test.secrets, test.pullSecrets, secrets, pullSecrets)
+			}
+			if linked := sa.Annotations[LinkedSecretsAnnotation]; linked != test.linked {
+				t.Errorf("expected '%s' linked secrets to be recorded, got '%s'", test.linked, linked)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package component
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"halkyon.io/api/component/v1beta1"
+	framework "halkyon.io/operator-framework"
+	"halkyon.io/operator/pkg/tekton"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"path"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+	"time"
+)
+
+const (
+	// UploadedSourceKey is the key of the source archive in the ConfigMaps storing uploaded archives
+	UploadedSourceKey = "source"
+	// SourceUploadLabelKey labels the ConfigMaps storing the source archives uploaded for a component with its name
+	SourceUploadLabelKey = "halkyon.io/source-upload"
+	// SourceDigestAttributeKey is the condition attribute recording the digest of the source archive a build built
+	SourceDigestAttributeKey = "SourceDigest"
+	extractStepName          = "extract"
+	sourceDigestResult       = "source-digest"
+	// sourceArchiveVolume holds the source archive in the build pods, mounted to sourceArchivePath
+	sourceArchiveVolume = "source-archive"
+	sourceArchivePath   = "/source-archive"
+	// sourceUploadGracePeriod leaves the time to the upload handler to point the component to the archive it just stored
+	sourceUploadGracePeriod = time.Minute
+)
+
+// SourceArchive locates a tar, tar.gz or zip archive of the source to build, held by a ConfigMap or stored in a PVC
+type SourceArchive struct {
+	ConfigMap             string `json:"configMap,omitempty"`
+	PersistentVolumeClaim string `json:"persistentVolumeClaim,omitempty"`
+	// Path is the key of the archive in the ConfigMap or its path in the PVC
+	Path string `json:"path"`
+}
+
+// SourceUploadName returns the name of the ConfigMap storing the source archive with the specified digest uploaded for
+// the specified component
+func SourceUploadName(c *v1beta1.Component, digest string) string {
+	hex := strings.TrimPrefix(digest, "sha256:")
+	if len(hex) > 12 {
+		hex = hex[:12]
+	}
+	return c.Name + "-source-" + hex
+}
+
+// sourceArchive returns the validated source archive the component is built from, as specified using the
+// SourceArchiveAnnotation, nil if it's built from its git repository
+func sourceArchive(c *v1beta1.Component) (*SourceArchive, error) {
+	archive := &SourceArchive{}
+	if found, err := decodeAnnotation(c, SourceArchiveAnnotation, archive); !found || err != nil {
+		return nil, err
+	}
+	if (len(archive.ConfigMap) > 0) == (len(archive.PersistentVolumeClaim) > 0) {
+		return nil, fmt.Errorf("invalid '%s' annotation on component '%s': exactly one of configMap or persistentVolumeClaim must be specified", SourceArchiveAnnotation, c.Name)
+	}
+	if clean := path.Clean(archive.Path); len(archive.Path) == 0 || path.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return nil, fmt.Errorf("invalid '%s' annotation on component '%s': path must be relative to the root of the ConfigMap or PVC", SourceArchiveAnnotation, c.Name)
+	}
+	return archive, nil
+}
+
+// pruneSourceUploads deletes the ConfigMaps storing the source archives uploaded for the component which are neither
+// built by the component anymore nor by the specified builds kept in its history, which might still be retried
+func pruneSourceUploads(c *v1beta1.Component, kept []tekton.TaskRun) error {
+	configMaps := &corev1.ConfigMapList{}
+	lo := &client.ListOptions{}
+	lo.InNamespace(c.Namespace)
+	lo.MatchingLabels(map[string]string{SourceUploadLabelKey: c.Name})
+	if err := framework.Helper.Client.List(context.TODO(), lo, configMaps); err != nil {
+		return err
+	}
+	for _, i := range prunedSourceUploads(c, kept, configMaps.Items, time.Now()) {
+		if err := framework.Helper.Client.Delete(context.TODO(), &configMaps.Items[i]); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// prunedSourceUploads returns the indexes of the specified upload ConfigMaps which neither the component nor the kept
+// builds reference, leaving alone the ones just created which the component might not reference yet
+func prunedSourceUploads(c *v1beta1.Component, kept []tekton.TaskRun, configMaps []corev1.ConfigMap, now time.Time) []int {
+	referenced := make(map[string]bool, len(kept)+1)
+	if archive, _ := sourceArchive(c); archive != nil {
+		referenced[archive.ConfigMap] = true
+	}
+	for _, build := range kept {
+		archive := &SourceArchive{}
+		if err := json.Unmarshal([]byte(build.Annotations[SourceArchiveAnnotation]), archive); err == nil {
+			referenced[archive.ConfigMap] = true
+		}
+	}
+	pruned := make([]int, 0, len(configMaps))
+	for i, configMap := range configMaps {
+		if !referenced[configMap.Name] && now.Sub(configMap.CreationTimestamp.Time) > sourceUploadGracePeriod {
+			pruned = append(pruned, i)
+		}
+	}
+	return pruned
+}
+
+func isSourceArchiveBuild(c *v1beta1.Component) bool {
+	archive, _ := sourceArchive(c)
+	return archive != nil
+}
+
+// sourceArchiveVolumeSource returns the volume holding the specified source archive in the build pods
+func sourceArchiveVolumeSource(archive *SourceArchive) corev1.VolumeSource {
+	if len(archive.ConfigMap) > 0 {
+		return corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+			LocalObjectReference: corev1.LocalObjectReference{Name: archive.ConfigMap},
+		}}
+	}
+	return corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+		ClaimName: archive.PersistentVolumeClaim,
+		ReadOnly:  true,
+	}}
+}
+
+// extractStep extracts the source archive into the source workspace instead of cloning the project, recording the
+// digest of the archive. The format of the archive is detected from its first bytes.
+func extractStep() tekton.Step {
+	return tekton.Step{
+		Container: corev1.Container{
+			Name:       extractStepName,
+			Image:      "busybox",
+			WorkingDir: "$(workspaces." + sourceWorkspace + ".path)",
+			// the path of the archive is passed through the environment so that it can't inject shell commands
+			Env: []corev1.EnvVar{{Name: "ARCHIVE", Value: "$(params.archive)"}},
+			VolumeMounts: []corev1.VolumeMount{
+				{Name: sourceArchiveVolume, MountPath: sourceArchivePath, ReadOnly: true},
+			},
+		},
+		Script: `#!/bin/sh
+set -eu
+archive="` + sourceArchivePath + `/$ARCHIVE"
+printf "sha256:%s" "$(sha256sum "$archive" | cut -d ' ' -f 1)" > "$(results.` + sourceDigestResult + `.path)"
+case "$(head -c 2 "$archive" | od -An -tx1 | tr -d ' \n')" in
+  504b) unzip -q "$archive" -d . ;;
+  1f8b) tar -xzf "$archive" ;;
+  *) tar -xf "$archive" ;;
+esac
+`,
+	}
+}
+
+// sourceDigest returns the digest of the source archive the specified build built, if any
+func sourceDigest(tr *tekton.TaskRun) string {
+	digest, _ := tr.Status.Result(sourceDigestResult)
+	return strings.TrimSpace(digest)
+}
@@ -0,0 +1,109 @@
+package component
+
+import (
+	"halkyon.io/operator/pkg/tekton"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+	"time"
+)
+
+func TestSourceArchive(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected SourceArchive
+	}{
+		{
+			name:     "config map",
+			value:    `{"configMap": "backend-source-0123456789ab", "path": "source"}`,
+			expected: SourceArchive{ConfigMap: "backend-source-0123456789ab", Path: "source"},
+		},
+		{
+			name:     "pvc",
+			value:    `{"persistentVolumeClaim": "sources", "path": "backend/v1.tar.gz"}`,
+			expected: SourceArchive{PersistentVolumeClaim: "sources", Path: "backend/v1.tar.gz"},
+		},
+		{
+			name:     "path within the pvc",
+			value:    `{"persistentVolumeClaim": "sources", "path": "backend/../frontend.zip"}`,
+			expected: SourceArchive{PersistentVolumeClaim: "sources", Path: "backend/../frontend.zip"},
+		},
+		{
+			name:     "name starting with dots",
+			value:    `{"persistentVolumeClaim": "sources", "path": "..src/backend.tar"}`,
+			expected: SourceArchive{PersistentVolumeClaim: "sources", Path: "..src/backend.tar"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestComponent(map[string]string{SourceArchiveAnnotation: test.value})
+			archive, err := sourceArchive(c)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if archive == nil || *archive != test.expected {
+				t.Errorf("expected %+v archive, got %+v", test.expected, archive)
+			}
+			if !isSourceArchiveBuild(c) {
+				t.Errorf("expected the component to be built from its source archive")
+			}
+		})
+	}
+
+	c := newTestComponent(nil)
+	if archive, err := sourceArchive(c); archive != nil || err != nil || isSourceArchiveBuild(c) {
+		t.Errorf("expected the component to be built from its git repository by default, got %+v: %v", archive, err)
+	}
+}
+
+func TestInvalidSourceArchive(t *testing.T) {
+	tests := map[string]string{
+		"no volume":         `{"path": "source"}`,
+		"both volumes":      `{"configMap": "backend-source", "persistentVolumeClaim": "sources", "path": "source"}`,
+		"no path":           `{"configMap": "backend-source"}`,
+		"absolute path":     `{"persistentVolumeClaim": "sources", "path": "/backend.tar"}`,
+		"parent path":       `{"persistentVolumeClaim": "sources", "path": "../backend.tar"}`,
+		"escaping path":     `{"persistentVolumeClaim": "sources", "path": "backend/../../backend.tar"}`,
+		"parent directory":  `{"persistentVolumeClaim": "sources", "path": "backend/../.."}`,
+		"root directory":    `{"persistentVolumeClaim": "sources", "path": "backend/.."}`,
+		"unknown field":     `{"configMap": "backend-source", "key": "source"}`,
+		"not a JSON object": `backend-source`,
+	}
+	for name, value := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := newTestComponent(map[string]string{SourceArchiveAnnotation: value})
+			if archive, err := sourceArchive(c); err == nil {
+				t.Errorf("expected '%s' to be rejected, got %+v", value, archive)
+			}
+			if isSourceArchiveBuild(c) {
+				t.Errorf("expected an invalid source archive not to be built")
+			}
+		})
+	}
+}
+
+func TestPrunedSourceUploads(t *testing.T) {
+	now := time.Now()
+	upload := func(name string, age time.Duration) corev1.ConfigMap {
+		return corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: metav1.Time{Time: now.Add(-age)}}}
+	}
+	build := func(number int, configMap string) tekton.TaskRun {
+		tr := newTestBuild(number)
+		tr.Annotations[SourceArchiveAnnotation] = `{"configMap": "` + configMap + `", "path": "source"}`
+		return tr
+	}
+	c := newTestComponent(map[string]string{SourceArchiveAnnotation: `{"configMap": "backend-source-current", "path": "source"}`})
+	kept := []tekton.TaskRun{build(3, "backend-source-current"), build(2, "backend-source-retried"), newTestBuild(1)}
+	configMaps := []corev1.ConfigMap{
+		upload("backend-source-current", time.Hour),
+		upload("backend-source-retried", time.Hour),
+		upload("backend-source-pruned", time.Hour),
+		upload("backend-source-uploading", time.Second),
+	}
+
+	pruned := prunedSourceUploads(c, kept, configMaps, now)
+	if len(pruned) != 1 || configMaps[pruned[0]].Name != "backend-source-pruned" {
+		t.Errorf("expected only backend-source-pruned to be pruned, got %v", pruned)
+	}
+}
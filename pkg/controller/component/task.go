@@ -1,13 +1,47 @@
 package component
 
 import (
-	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
 	"halkyon.io/api/component/v1beta1"
 	"halkyon.io/operator-framework"
 	"halkyon.io/operator-framework/util"
+	"halkyon.io/operator/pkg/tekton"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"strconv"
+	"strings"
+)
+
+const (
+	buildahImage    = "quay.io/buildah/stable:v1.14.8"
+	gitImage        = "alpine/git:v2.24.3"
+	skopeoImage     = "quay.io/skopeo/stable:v1.2.0"
+	prepareStepName = "prepare"
+	cloneStepName   = "clone"
+	pushStepName    = "push"
+	tagStepName     = "tag"
+	// sourceWorkspace is the workspace the project is cloned to
+	sourceWorkspace = "source"
+	// commitResult and imageDigestResult are the results recording the SHA of the built commit and the digest of the
+	// pushed image
+	commitResult      = "commit"
+	imageDigestResult = "image-digest"
+	// cacheWorkspace holds what's kept between builds when the component uses a build cache: the dependencies downloaded
+	// by the build tools, the buildpacks cache and the container layers
+	cacheWorkspace    = "cache"
+	cachePath         = "$(workspaces." + cacheWorkspace + ".path)"
+	dependenciesCache = cachePath + "/dependencies"
+	buildpacksCache   = cachePath + "/buildpacks"
+	containersStorage = cachePath + "/containers"
+	// dependenciesMountPath is where the dependencies cache is mounted while building images from generated Dockerfiles
+	dependenciesMountPath = "/tmp/cache"
+	// buildpacksCreator runs all the phases of the buildpacks lifecycle provided by the builder images
+	buildpacksCreator = "/cnb/lifecycle/creator"
+	// tektonHome is the home directory of the steps, where Tekton writes the credentials of the build service account
+	tektonHome = "/tekton/home"
 )
 
 type task struct {
@@ -17,7 +51,7 @@ type task struct {
 var _ framework.DependentResource = &task{}
 
 func newTask(owner *v1beta1.Component) task {
-	config := framework.NewConfig(v1alpha1.SchemeGroupVersion.WithKind("Task"))
+	config := framework.NewConfig(tekton.SchemeGroupVersion.WithKind("Task"))
 	config.CheckedForReadiness = v1beta1.BuildDeploymentMode == owner.Spec.DeploymentMode
 	config.Created = config.CheckedForReadiness
 	config.Updated = config.CheckedForReadiness
@@ -27,137 +61,474 @@ func newTask(owner *v1beta1.Component) task {
 }
 
 func (res task) Build(empty bool) (runtime.Object, error) {
-	task := &v1alpha1.Task{}
+	task := &tekton.Task{}
 	if !empty {
 		c := res.ownerAsComponent()
 		task.ObjectMeta = metav1.ObjectMeta{
 			Namespace: c.Namespace,
 			Name:      res.Name(),
 		}
-		task.Spec = v1alpha1.TaskSpec{
-			Inputs: &v1alpha1.Inputs{
-				// This input corresponds to a pre-task step as the project will be cloned
-				// under by default the following directory : /workspace/{resource-name}
-				// Resource name has been defined to git hereafter
-				Resources: []v1alpha1.TaskResource{{
-					ResourceDeclaration: v1alpha1.ResourceDeclaration{
-						Name: "git",
-						Type: "git",
-					},
-				}},
-				Params: []v1alpha1.ParamSpec{
-					{Name: "baseImage", Type: v1alpha1.ParamTypeString, Default: &v1alpha1.ArrayOrString{Type: v1alpha1.ParamTypeString, StringVal: "quay.io/halkyonio/spring-boot-maven-s2i"}, Description: "S2i base image"},
-					{Name: "contextPath", Type: v1alpha1.ParamTypeString, Default: &v1alpha1.ArrayOrString{Type: v1alpha1.ParamTypeString, StringVal: "."}, Description: "The location of the path to run s2i from"},
-					{Name: "moduleDirName", Type: v1alpha1.ParamTypeString, Default: &v1alpha1.ArrayOrString{Type: v1alpha1.ParamTypeString, StringVal: "."}, Description: "The name of the directory containing the project (maven, ...) to be compiled"},
-					{Name: "verifyTLS", Type: v1alpha1.ParamTypeString, Default: &v1alpha1.ArrayOrString{Type: v1alpha1.ParamTypeString, StringVal: "false"}, Description: "Verify registry certificates"},
-					{Name: "workspacePath", Type: v1alpha1.ParamTypeString, Default: &v1alpha1.ArrayOrString{Type: v1alpha1.ParamTypeString, StringVal: "/workspace/git"}, Description: "Git path where project is cloned"},
-				},
-			},
-			Outputs: &v1alpha1.Outputs{
-				Resources: []v1alpha1.TaskResource{{
-					ResourceDeclaration: v1alpha1.ResourceDeclaration{
-						Name: "image",
-						Type: "image",
-					},
-				}},
+		if isDockerBuild(c) {
+			task.Spec = dockerfileTaskSpec()
+		} else if isBuildpacksBuild(c) {
+			task.Spec = buildpacksTaskSpec()
+		} else {
+			task.Spec = s2iTaskSpec()
+		}
+		// all the builds prepare the cache and clone the project first, record the built commit and the digest of the
+		// pushed image and finally tag it
+		task.Spec.Params = append(sourceParams(), task.Spec.Params...)
+		source := cloneStep()
+		if archive, _ := sourceArchive(c); archive != nil {
+			// components built from a source archive extract it instead
+			source = extractStep()
+			task.Spec.Volumes = append(task.Spec.Volumes, corev1.Volume{Name: sourceArchiveVolume, VolumeSource: sourceArchiveVolumeSource(archive)})
+		}
+		task.Spec.Steps = append([]tekton.Step{cacheStep(), source}, task.Spec.Steps...)
+		task.Spec.Steps = append(task.Spec.Steps, tagStep())
+		task.Spec.Workspaces = []tekton.WorkspaceDeclaration{
+			{Name: sourceWorkspace, Description: "The cloned project"},
+			{Name: cacheWorkspace, Description: "The cache kept between builds"},
+		}
+		task.Spec.Results = []tekton.TaskResult{
+			{Name: commitResult, Description: "The SHA of the built commit"},
+			{Name: imageDigestResult, Description: "The digest of the pushed image"},
+			{Name: sourceDigestResult, Description: "The digest of the built source archive, if any"},
+		}
+		trustRegistryCA(&task.Spec, RegistryCAName(c))
+		if isUnprivilegedBuild(c) {
+			makeUnprivileged(&task.Spec)
+		}
+		task.Annotations = map[string]string{TaskSpecHashAnnotation: taskSpecHash(task.Spec)}
+	}
+
+	return task, nil
+}
+
+func stringParam(name, defaultValue, description string) tekton.ParamSpec {
+	value := tekton.NewString(defaultValue)
+	return tekton.ParamSpec{Name: name, Type: tekton.ParamTypeString, Default: &value, Description: description}
+}
+
+// sourceParams declares the parameters common to all builds
+func sourceParams() []tekton.ParamSpec {
+	tags := tekton.NewArray()
+	return []tekton.ParamSpec{
+		{Name: "url", Type: tekton.ParamTypeString, Description: "The URL of the git repository to clone"},
+		stringParam("revision", "master", "The git revision to build"),
+		stringParam("commit", "", "The commit of the revision to build, its head if empty"),
+		stringParam("archive", "", "The path of the source archive to build instead, relative to its volume"),
+		{Name: "image", Type: tekton.ParamTypeString, Description: "The reference of the image to push"},
+		{Name: "tags", Type: tekton.ParamTypeArray, Default: &tags, Description: "The tags of the pushed image, in addition to the built commit"},
+		stringParam("verifyTLS", "true", "Verify registry certificates"),
+		stringParam("purgeCache", "false", "Purge the cache before building"),
+	}
+}
+
+// cacheStep purges the cache if requested then creates the cache directories, letting the builder images' users write
+// to them
+func cacheStep() tekton.Step {
+	return tekton.Step{
+		Container: corev1.Container{
+			Name:  "cache",
+			Image: "busybox",
+			Env:   []corev1.EnvVar{{Name: "PURGE_CACHE", Value: "$(params.purgeCache)"}},
+		},
+		Script: `#!/bin/sh
+set -eu
+if [ "$PURGE_CACHE" = "true" ]; then
+  echo "Purging the build cache"
+  rm -rf "` + cachePath + `"/*
+fi
+mkdir -p "` + dependenciesCache + `" "` + buildpacksCache + `"
+chmod a+rwx "` + dependenciesCache + `" "` + buildpacksCache + `" 2>/dev/null || true
+`,
+	}
+}
+
+// cloneStep clones the project into the source workspace, recording the checked out commit: the specified commit of the
+// revision if any, its head otherwise
+func cloneStep() tekton.Step {
+	return tekton.Step{
+		Container: corev1.Container{
+			Name:       cloneStepName,
+			Image:      gitImage,
+			WorkingDir: "$(workspaces." + sourceWorkspace + ".path)",
+			Env: []corev1.EnvVar{
+				// Tekton writes the git credentials of the build service account to the home directory
+				{Name: "HOME", Value: tektonHome},
+				{Name: "GIT_TERMINAL_PROMPT", Value: "0"},
+				// the parameters are passed through the environment rather than substituted in the script, which
+				// would let their values inject shell commands
+				{Name: "URL", Value: "$(params.url)"},
+				{Name: "REVISION", Value: "$(params.revision)"},
+				{Name: "COMMIT", Value: "$(params.commit)"},
 			},
-			Steps: []v1alpha1.Step{
-				{Container: corev1.Container{
-					// # Generate a Dockerfile using the s2i tool
-					Name:  "generate",
-					Image: "quay.io/openshift-pipeline/s2i",
-					Command: []string{
-						"s2i",
-						"build",
-					},
-					Args: []string{
-						"$(inputs.params.workspacePath)/$(inputs.params.contextPath)",
-						"$(inputs.params.baseImage)",
-						"--as-dockerfile",
-						"/sources/Dockerfile.gen",
-						"--image-scripts-url",
-						"image:///usr/local/s2i",
-						"--loglevel",
-						"5",
-						"--env",
-						"MAVEN_ARGS_APPEND=-pl $(inputs.params.moduleDirName)",
-						"--env",
-						"MAVEN_S2I_ARTIFACT_DIRS=$(inputs.params.moduleDirName)/target",
-						"--env",
-						"S2I_SOURCE_DEPLOYMENTS_FILTER=*.jar",
-					},
-					VolumeMounts: []corev1.VolumeMount{
-						{
-							MountPath: "/sources",
-							Name:      "generatedsources"},
-					},
-				}},
-				{Container: corev1.Container{
-					// Build a Container image using the dockerfile created previously
-					Name:       "build",
-					Image:      "quay.io/buildah/stable:v1.9.0",
-					WorkingDir: "/sources",
-					Command: []string{
-						"buildah",
-					},
-					Args: []string{
-						"bud",
-						"--tls-verify=$(inputs.params.verifyTLS)",
-						"--layers",
-						"-f",
-						"/sources/Dockerfile.gen",
-						"-t",
-						"$(outputs.resources.image.url)",
-						"."},
-					VolumeMounts: []corev1.VolumeMount{
-						{
-							Name:      "libcontainers",
-							MountPath: "/var/lib/containers",
-						},
-						{
-							Name:      "generatedsources",
-							MountPath: "/sources",
-						},
-					},
-					SecurityContext: &corev1.SecurityContext{
-						Privileged: util.NewTrue(),
-					},
-				}},
-				{Container: corev1.Container{
-					// Push the image created to quay.io using as credentials the secret mounted within
-					// the service account
-					Name:  "push",
-					Image: "quay.io/buildah/stable:v1.9.0",
-					Command: []string{
-						"buildah",
-					},
-					Env: []corev1.EnvVar{
-						{Name: "REGISTRY_AUTH_FILE", Value: "/home/builder/.docker/config.json"},
-					},
-					Args: []string{
-						"push",
-						"--tls-verify=$(inputs.params.verifyTLS)",
-						"$(outputs.resources.image.url)",
-						"docker://$(outputs.resources.image.url)",
+		},
+		Script: `#!/bin/sh
+set -eu
+git init -q .
+git remote add origin "$URL"
+revision="$REVISION"
+if [ -n "$COMMIT" ]; then
+  revision="$COMMIT"
+fi
+if ! output="$(git fetch -q --depth 1 origin "$revision" 2>&1)"; then
+  echo "$output" >&2
+  case "$output" in
+    *"Authentication failed"*|*"could not read Username"*|*"Permission denied"*|*"returned error: 401"*|*"returned error: 403"*)
+      exit ` + strconv.Itoa(gitAuthenticationExitCode) + `;;
+  esac
+  exit 1
+fi
+git checkout -q FETCH_HEAD
+printf "%s" "$(git rev-parse HEAD)" > "$(results.` + commitResult + `.path)"
+`,
+	}
+}
+
+// s2iTaskSpec generates a Dockerfile using source-to-image, builds it then pushes the resulting image
+func s2iTaskSpec() tekton.TaskSpec {
+	buildEnv := tekton.NewArray()
+	return tekton.TaskSpec{
+		Params: []tekton.ParamSpec{
+			stringParam("baseImage", "quay.io/halkyonio/spring-boot-maven-s2i", "S2i base image"),
+			stringParam("scriptsUrl", defaultS2IScriptsURL, "The location of the s2i scripts, read from the base image's labels if empty"),
+			stringParam("contextPath", ".", "The location of the path to run s2i from"),
+			stringParam("logLevel", "5", "The log level of s2i"),
+			{Name: "buildEnv", Type: tekton.ParamTypeArray, Default: &buildEnv, Description: "The --env flags to pass to s2i"},
+		},
+		Steps: []tekton.Step{
+			{Container: corev1.Container{
+				// # Generate a Dockerfile using the s2i tool
+				Name:  "generate",
+				Image: "quay.io/openshift-pipeline/s2i",
+				Command: []string{
+					"s2i",
+					"build",
+				},
+				Args: []string{
+					"$(workspaces." + sourceWorkspace + ".path)/$(params.contextPath)",
+					"$(params.baseImage)",
+					"--as-dockerfile",
+					"/sources/Dockerfile.gen",
+					"--image-scripts-url",
+					"$(params.scriptsUrl)",
+					"--loglevel",
+					"$(params.logLevel)",
+					// point the build tools to the dependencies cache, unless overridden by the build env
+					"--env",
+					"MAVEN_OPTS=-Dmaven.repo.local=" + dependenciesMountPath + "/m2",
+					"--env",
+					"npm_config_cache=" + dependenciesMountPath + "/npm",
+					"$(params.buildEnv)",
+				},
+				VolumeMounts: []corev1.VolumeMount{
+					{
+						MountPath: "/sources",
+						Name:      "generatedsources"},
+				},
+			}},
+			{Container: corev1.Container{
+				// Build a Container image using the dockerfile created previously
+				Name:       "build",
+				Image:      buildahImage,
+				WorkingDir: "/sources",
+				Command: []string{
+					"buildah",
+				},
+				Args: []string{
+					"--root=" + containersStorage,
+					"bud",
+					"--tls-verify=$(params.verifyTLS)",
+					"--layers",
+					"--volume=" + dependenciesCache + ":" + dependenciesMountPath,
+					"-f",
+					"/sources/Dockerfile.gen",
+					"-t",
+					"$(params.image)",
+					"."},
+				VolumeMounts: []corev1.VolumeMount{
+					{
+						Name:      "generatedsources",
+						MountPath: "/sources",
 					},
+				},
+				SecurityContext: &corev1.SecurityContext{
+					Privileged: util.NewTrue(),
+				},
+			}},
+			pushStep(),
+		},
+		Volumes: []corev1.Volume{
+			{Name: "generatedsources", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+		},
+	}
+}
+
+// dockerfileTaskSpec builds a Dockerfile found in the cloned project then pushes the resulting image
+func dockerfileTaskSpec() tekton.TaskSpec {
+	buildArgs := tekton.NewArray()
+	return tekton.TaskSpec{
+		Params: []tekton.ParamSpec{
+			stringParam("contextPath", ".", "The location of the build context"),
+			stringParam("dockerfile", defaultDockerfile, "The path of the Dockerfile, relative to the build context"),
+			{Name: "buildArgs", Type: tekton.ParamTypeArray, Default: &buildArgs, Description: "The --build-arg flags to pass to the build"},
+		},
+		Steps: []tekton.Step{
+			{Container: corev1.Container{
+				// Build a Container image using the project's dockerfile
+				Name:       "build",
+				Image:      buildahImage,
+				WorkingDir: "$(workspaces." + sourceWorkspace + ".path)/$(params.contextPath)",
+				Command: []string{
+					"buildah",
+				},
+				Args: []string{
+					"--root=" + containersStorage,
+					"bud",
+					"--tls-verify=$(params.verifyTLS)",
+					"--layers",
+					"-f",
+					"$(params.dockerfile)",
+					"-t",
+					"$(params.image)",
+					"$(params.buildArgs)",
+					"."},
+				SecurityContext: &corev1.SecurityContext{
+					Privileged: util.NewTrue(),
+				},
+			}},
+			pushStep(),
+		},
+	}
+}
+
+// buildpacksTaskSpec builds and pushes the image using the Cloud Native Buildpacks lifecycle provided by the builder image
+func buildpacksTaskSpec() tekton.TaskSpec {
+	return tekton.TaskSpec{
+		Params: []tekton.ParamSpec{
+			stringParam("builderImage", defaultBuildpacksBuilder, "The image providing the buildpacks and the lifecycle"),
+			stringParam("contextPath", ".", "The location of the application source"),
+			stringParam("userId", "1000", "The user id of the builder image's user"),
+			stringParam("groupId", "1000", "The group id of the builder image's user"),
+		},
+		Steps: []tekton.Step{
+			{Container: corev1.Container{
+				// Give the builder image's user ownership of the directories the lifecycle writes to
+				Name:  prepareStepName,
+				Image: "busybox",
+				Command: []string{
+					"chown",
+				},
+				Args: []string{
+					"-R",
+					"$(params.userId):$(params.groupId)",
+					"/layers",
+					buildpacksCache,
+					tektonHome,
+					"$(workspaces." + sourceWorkspace + ".path)",
+				},
+				VolumeMounts: []corev1.VolumeMount{
+					{Name: "layers", MountPath: "/layers"},
+				},
+			}},
+			{Container: corev1.Container{
+				// Detect, build and export the image then push it to the registry using as credentials the pull
+				// secrets linked to the service account, which Tekton writes to the docker config of its home directory
+				Name:  "create",
+				Image: "$(params.builderImage)",
+				Command: []string{
+					buildpacksCreator,
+				},
+				Args: []string{
+					"-app=$(workspaces." + sourceWorkspace + ".path)/$(params.contextPath)",
+					"-cache-dir=" + buildpacksCache,
+					"-layers=/layers",
+					"-platform=/platform",
+					"-report=/layers/report.toml",
+					"$(params.image)",
+				},
+				Env: []corev1.EnvVar{
+					{Name: "DOCKER_CONFIG", Value: tektonHome + "/.docker"},
+				},
+				VolumeMounts: []corev1.VolumeMount{
+					{Name: "layers", MountPath: "/layers"},
+					{Name: "platform", MountPath: "/platform"},
+				},
+			}},
+			{
+				Container: corev1.Container{
+					// Record the digest of the pushed image from the lifecycle's report
+					Name:  "digest",
+					Image: "busybox",
 					VolumeMounts: []corev1.VolumeMount{
-						{
-							MountPath: "/var/lib/containers",
-							Name:      "libcontainers"},
+						{Name: "layers", MountPath: "/layers"},
 					},
-					SecurityContext: &corev1.SecurityContext{
-						Privileged: util.NewTrue(),
-					},
-				}},
-			},
-			Volumes: []corev1.Volume{
-				{Name: "generatedsources", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
-				{Name: "libcontainers", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+				},
+				Script: `#!/bin/sh
+set -eu
+sed -n 's/^ *digest *= *"\(.*\)"/\1/p' /layers/report.toml | head -n 1 | tr -d '\n' > "$(results.` + imageDigestResult + `.path)"
+`,
 			},
+		},
+		Volumes: []corev1.Volume{
+			{Name: "layers", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+			{Name: "platform", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+		},
+	}
+}
+
+func pushStep() tekton.Step {
+	return tekton.Step{Container: corev1.Container{
+		// Push the image created to the registry using as credentials the pull secrets linked to
+		// the service account, which Tekton writes to the docker config of its home directory
+		Name:  pushStepName,
+		Image: buildahImage,
+		Command: []string{
+			"buildah",
+		},
+		Env: []corev1.EnvVar{
+			{Name: "REGISTRY_AUTH_FILE", Value: tektonHome + "/.docker/config.json"},
+		},
+		Args: []string{
+			"--root=" + containersStorage,
+			"push",
+			"--tls-verify=$(params.verifyTLS)",
+			"--digestfile=$(results." + imageDigestResult + ".path)",
+			"$(params.image)",
+			"docker://$(params.image)",
+		},
+		SecurityContext: &corev1.SecurityContext{
+			Privileged: util.NewTrue(),
+		},
+	}}
+}
+
+// trustRegistryCA mounts the CA bundle of the registry, held by the specified ConfigMap, in the steps accessing the
+// registry: buildah reads the certificates of its --cert-dir while the buildpacks lifecycle adds the ones of SSL_CERT_DIR
+// to the system ones
+func trustRegistryCA(spec *tekton.TaskSpec, configMap string) {
+	spec.Volumes = append(spec.Volumes, corev1.Volume{Name: registryCAVolume, VolumeSource: corev1.VolumeSource{
+		ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: configMap}},
+	}})
+	mount := corev1.VolumeMount{Name: registryCAVolume, MountPath: registryCertsPath, ReadOnly: true}
+	for i := range spec.Steps {
+		step := &spec.Steps[i].Container
+		if step.Name == tagStepName {
+			// skopeo is passed the certificates directory by the step's script
+			step.VolumeMounts = append(step.VolumeMounts, mount)
+			continue
+		}
+		if len(step.Command) == 0 {
+			continue
+		}
+		switch step.Command[0] {
+		case "buildah":
+			// the flag belongs to the bud and push commands, which follow the global flags
+			for j, arg := range step.Args {
+				if !strings.HasPrefix(arg, "-") {
+					step.Args = append(step.Args[:j+1], append([]string{"--cert-dir=" + registryCertsPath}, step.Args[j+1:]...)...)
+					break
+				}
+			}
+			step.VolumeMounts = append(step.VolumeMounts, mount)
+		case buildpacksCreator:
+			step.Env = append(step.Env, corev1.EnvVar{Name: "SSL_CERT_DIR", Value: registryCertsPath})
+			step.VolumeMounts = append(step.VolumeMounts, mount)
 		}
 	}
+}
 
-	return task, nil
+// tagStep tags the pushed image with the SHA of the built commit and the tags passed to the build. Tagging only copies
+// the manifest of the pushed image since its layers already belong to the repository.
+func tagStep() tekton.Step {
+	return tekton.Step{Container: corev1.Container{
+		Name:  tagStepName,
+		Image: skopeoImage,
+		Command: []string{
+			"/bin/sh",
+			"-c",
+			`set -eu
+digest="$(cat "$(results.` + imageDigestResult + `.path)")"
+source="$IMAGE"
+if [ -n "$digest" ]; then
+  source="$IMAGE@$digest"
+fi
+for tag in "$(cat "$(results.` + commitResult + `.path)")" "$@"; do
+  [ -n "$tag" ] || continue
+  echo "Tagging $source as $tag"
+  skopeo copy --src-tls-verify="$VERIFY_TLS" --dest-tls-verify="$VERIFY_TLS" \
+    --src-cert-dir=` + registryCertsPath + ` --dest-cert-dir=` + registryCertsPath + ` \
+    "docker://$source" "docker://$IMAGE:$tag"
+done
+`,
+			tagStepName,
+		},
+		Args: []string{
+			"$(params.tags)",
+		},
+		Env: []corev1.EnvVar{
+			{Name: "REGISTRY_AUTH_FILE", Value: tektonHome + "/.docker/config.json"},
+			{Name: "IMAGE", Value: "$(params.image)"},
+			{Name: "VERIFY_TLS", Value: "$(params.verifyTLS)"},
+		},
+	}}
+}
+
+// makeUnprivileged adapts the build steps to run rootless, buildah then using the vfs storage driver and chroot isolation.
+// Preparation steps requiring root are dropped: the directories they prepare are then expected to be writable by the
+// build user, the group of the build pod's volumes.
+func makeUnprivileged(spec *tekton.TaskSpec) {
+	steps := make([]tekton.Step, 0, len(spec.Steps))
+	for _, step := range spec.Steps {
+		if step.Name != prepareStepName {
+			steps = append(steps, step)
+		}
+	}
+	spec.Steps = steps
+	for i := range spec.Steps {
+		step := &spec.Steps[i].Container
+		buildah := len(step.Command) > 0 && step.Command[0] == "buildah"
+		step.SecurityContext = unprivilegedBuildSecurityContext(buildah)
+		if buildah {
+			// keep the layers stored using vfs apart as the storage driver of the cached layers cannot be changed
+			for j, arg := range step.Args {
+				if arg == "--root="+containersStorage {
+					step.Args[j] = arg + "-vfs"
+				}
+			}
+			step.Args = append([]string{"--storage-driver=vfs"}, step.Args...)
+			step.Env = append(step.Env, corev1.EnvVar{Name: "BUILDAH_ISOLATION", Value: "chroot"})
+		}
+	}
+}
+
+// Update replaces the spec of the Task when the build configuration of the component changed, e.g. its build type. The
+// hash of the generated spec is compared instead of the spec itself as Tekton sets default values on the stored Task.
+func (res task) Update(toUpdate runtime.Object) (bool, runtime.Object, error) {
+	current := toUpdate.(*tekton.Task)
+	built, err := res.Build(false)
+	if err != nil {
+		return false, toUpdate, err
+	}
+	desired := built.(*tekton.Task)
+	hash := desired.Annotations[TaskSpecHashAnnotation]
+	if current.Annotations[TaskSpecHashAnnotation] == hash {
+		return false, current, nil
+	}
+	if current.Annotations == nil {
+		current.Annotations = make(map[string]string, 1)
+	}
+	current.Annotations[TaskSpecHashAnnotation] = hash
+	current.Spec = desired.Spec
+	return true, current, nil
+}
+
+// taskSpecHash computes a hash of the JSON representation of the specified Task spec
+func taskSpecHash(spec tekton.TaskSpec) string {
+	data, _ := json.Marshal(spec) // the spec only holds serializable values
+	return fmt.Sprintf("%x", sha256.Sum256(data))
 }
 
 func (res task) Name() string {
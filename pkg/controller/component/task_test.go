@@ -0,0 +1,146 @@
+package component
+
+import (
+	"halkyon.io/operator/pkg/tekton"
+	corev1 "k8s.io/api/core/v1"
+	"reflect"
+	"testing"
+)
+
+func findStep(spec tekton.TaskSpec, name string) *tekton.Step {
+	for i := range spec.Steps {
+		if spec.Steps[i].Name == name {
+			return &spec.Steps[i]
+		}
+	}
+	return nil
+}
+
+func hasEnv(step *tekton.Step, name, value string) bool {
+	for _, env := range step.Env {
+		if env.Name == name && env.Value == value {
+			return true
+		}
+	}
+	return false
+}
+
+func hasRegistryCA(step *tekton.Step) bool {
+	for _, mount := range step.VolumeMounts {
+		if mount.Name == registryCAVolume && mount.MountPath == registryCertsPath {
+			return true
+		}
+	}
+	return false
+}
+
+func TestTaskSteps(t *testing.T) {
+	tests := []struct {
+		name         string
+		buildType    string
+		annotations  map[string]string
+		steps        []string
+		unprivileged bool
+	}{
+		{name: "s2i", steps: []string{"cache", cloneStepName, "generate", "build", pushStepName, tagStepName}},
+		{name: "docker", buildType: DockerBuildType, steps: []string{"cache", cloneStepName, "build", pushStepName, tagStepName}},
+		{name: "buildpacks", buildType: BuildpacksBuildType, steps: []string{"cache", cloneStepName, prepareStepName, "create", "digest", tagStepName}},
+		{
+			name:        "source archive",
+			annotations: map[string]string{SourceArchiveAnnotation: `{"configMap": "backend-source-0123456789ab", "path": "source"}`},
+			steps:       []string{"cache", extractStepName, "generate", "build", pushStepName, tagStepName},
+		},
+		{
+			name:         "unprivileged docker",
+			buildType:    DockerBuildType,
+			annotations:  map[string]string{UnprivilegedBuildAnnotation: "true"},
+			steps:        []string{"cache", cloneStepName, "build", pushStepName, tagStepName},
+			unprivileged: true,
+		},
+		{
+			name:         "unprivileged buildpacks",
+			buildType:    BuildpacksBuildType,
+			annotations:  map[string]string{UnprivilegedBuildAnnotation: "true"},
+			steps:        []string{"cache", cloneStepName, "create", "digest", tagStepName},
+			unprivileged: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestComponent(test.annotations)
+			c.Spec.BuildConfig.Type = test.buildType
+			built, err := newTask(c).Build(false)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			spec := built.(*tekton.Task).Spec
+			names := make([]string, 0, len(spec.Steps))
+			for _, step := range spec.Steps {
+				names = append(names, step.Name)
+			}
+			if !reflect.DeepEqual(names, test.steps) {
+				t.Errorf("expected %v steps, got %v", test.steps, names)
+			}
+
+			if clone := findStep(spec, cloneStepName); clone != nil {
+				if !hasEnv(clone, "URL", "$(params.url)") || !hasEnv(clone, "REVISION", "$(params.revision)") || !hasEnv(clone, "COMMIT", "$(params.commit)") {
+					t.Errorf("expected the clone step to be passed the source parameters through its environment, got %v", clone.Env)
+				}
+			}
+
+			if push := findStep(spec, pushStepName); push != nil {
+				args := push.Args
+				if test.unprivileged {
+					if len(args) == 0 || args[0] != "--storage-driver=vfs" {
+						t.Fatalf("expected unprivileged push to use the vfs storage driver, got %v", args)
+					}
+					args = args[1:]
+				}
+				root := "--root=" + containersStorage
+				if test.unprivileged {
+					root += "-vfs"
+				}
+				expected := []string{root, "push", "--cert-dir=" + registryCertsPath, "--tls-verify=$(params.verifyTLS)",
+					"--digestfile=$(results." + imageDigestResult + ".path)", "$(params.image)", "docker://$(params.image)"}
+				if !reflect.DeepEqual(args, expected) {
+					t.Errorf("expected %v push arguments, got %v", expected, args)
+				}
+				if !hasRegistryCA(push) {
+					t.Errorf("expected the push step to mount the registry CA bundle")
+				}
+				if privileged := push.SecurityContext.Privileged; (privileged != nil && *privileged) == test.unprivileged {
+					t.Errorf("expected the push step to be privileged: %t", !test.unprivileged)
+				}
+			}
+
+			tag := findStep(spec, tagStepName)
+			if tag == nil {
+				t.Fatalf("expected a %s step", tagStepName)
+			}
+			if !reflect.DeepEqual(tag.Args, []string{"$(params.tags)"}) {
+				t.Errorf("expected the tags to be passed as arguments of the tag step, got %v", tag.Args)
+			}
+			if !hasEnv(tag, "IMAGE", "$(params.image)") || !hasEnv(tag, "VERIFY_TLS", "$(params.verifyTLS)") {
+				t.Errorf("expected the tag step to be passed the image parameters through its environment, got %v", tag.Env)
+			}
+			if !hasRegistryCA(tag) {
+				t.Errorf("expected the tag step to mount the registry CA bundle")
+			}
+		})
+	}
+}
+
+func TestBuildpacksTrustRegistryCA(t *testing.T) {
+	spec := buildpacksTaskSpec()
+	trustRegistryCA(&spec, "backend-registry-ca")
+	create := findStep(spec, "create")
+	if !hasEnv(create, "SSL_CERT_DIR", registryCertsPath) || !hasRegistryCA(create) {
+		t.Errorf("expected the lifecycle to trust the registry CA bundle, got %v env and %v mounts", create.Env, create.VolumeMounts)
+	}
+	expected := corev1.Volume{Name: registryCAVolume, VolumeSource: corev1.VolumeSource{
+		ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "backend-registry-ca"}},
+	}}
+	if volume := spec.Volumes[len(spec.Volumes)-1]; !reflect.DeepEqual(volume, expected) {
+		t.Errorf("expected %+v volume, got %+v", expected, volume)
+	}
+}
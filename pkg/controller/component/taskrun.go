@@ -1,14 +1,18 @@
 package component
 
 import (
+	"encoding/json"
 	"fmt"
-	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
 	"halkyon.io/api/component/v1beta1"
 	beta1 "halkyon.io/api/v1beta1"
 	"halkyon.io/operator-framework"
+	"halkyon.io/operator/pkg/tekton"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"knative.dev/pkg/apis"
+	"strconv"
+	"strings"
 )
 
 type taskRun struct {
@@ -18,15 +22,23 @@ type taskRun struct {
 var _ framework.DependentResource = &taskRun{}
 
 func newTaskRun(owner *v1beta1.Component) taskRun {
-	config := framework.NewConfig(v1alpha1.SchemeGroupVersion.WithKind("TaskRun"))
+	config := framework.NewConfig(tekton.SchemeGroupVersion.WithKind("TaskRun"))
 	config.CheckedForReadiness = v1beta1.BuildDeploymentMode == owner.Spec.DeploymentMode
 	config.Created = config.CheckedForReadiness
 	config.Updated = config.CheckedForReadiness
-	return taskRun{base: newConfiguredBaseDependent(owner, config)}
+	t := taskRun{base: newConfiguredBaseDependent(owner, config)}
+	t.NameFn = t.Name
+	return t
+}
+
+// Name returns the name of the component's current build so that a new TaskRun gets created when the build number
+// changes, thus triggering a new build while keeping the previous ones
+func (res taskRun) Name() string {
+	return buildName(res.ownerAsComponent())
 }
 
 func (res taskRun) Build(empty bool) (runtime.Object, error) {
-	taskRun := &v1alpha1.TaskRun{}
+	taskRun := &tekton.TaskRun{}
 	if !empty {
 		c := res.ownerAsComponent()
 		ls := getBuildLabels(c.Name)
@@ -34,54 +46,92 @@ func (res taskRun) Build(empty bool) (runtime.Object, error) {
 			Namespace: c.Namespace,
 			Name:      res.Name(),
 			Labels:    ls,
+			Annotations: map[string]string{
+				BuildFingerprintAnnotation: buildFingerprint(c),
+				BuildNumberAnnotation:      strconv.Itoa(buildNumber(c)),
+				BuildAttemptAnnotation:     strconv.Itoa(buildAttempt(c)),
+			},
+		}
+		if value, found := getAnnotation(c, PurgeBuildCacheAnnotation); found {
+			taskRun.Annotations[PurgeBuildCacheAnnotation] = value
+		}
+		purge, err := purgeBuildCache(c)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkSharedBuildCache(c, purge); err != nil {
+			return nil, err
+		}
+		scheduling, err := getScheduling(c, BuildSchedulingAnnotation)
+		if err != nil {
+			return nil, err
+		}
+		params, err := buildParams(c)
+		if err != nil {
+			return nil, err
+		}
+		timeout, err := buildTimeout(c)
+		if err != nil {
+			return nil, err
+		}
+		destination, err := getRegistryConfig(c.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		image, err := destination.imageURL(c)
+		if err != nil {
+			return nil, err
+		}
+		archive, err := sourceArchive(c)
+		if err != nil {
+			return nil, err
+		}
+		archivePath := ""
+		if archive != nil {
+			archivePath = archive.Path
+			// the uploaded archive is kept as long as the build is kept in the history
+			taskRun.Annotations[SourceArchiveAnnotation], _ = getAnnotation(c, SourceArchiveAnnotation)
+		}
+		// builds triggered by a push or a poll check the notified commit out, which the ref might not point to anymore
+		commit := triggeredCommit(c)
+		if len(commit) > 0 {
+			taskRun.Annotations[CommitAnnotation] = commit
+		}
+		podTemplate := &tekton.PodTemplate{
+			NodeSelector: scheduling.NodeSelector,
+			Tolerations:  scheduling.Tolerations,
+			Affinity:     scheduling.Affinity,
+			// Tekton passes them to the build pods, which requires Kubernetes 1.19
+			TopologySpreadConstraints: scheduling.TopologySpreadConstraints,
 		}
-		taskRun.Spec = v1alpha1.TaskRunSpec{
+		if isUnprivilegedBuild(c) {
+			podTemplate.SecurityContext = unprivilegedBuildPodSecurityContext()
+			// Tekton propagates the annotations of the TaskRun to its pod, for the clusters predating the seccompProfile field
+			taskRun.Annotations[seccompPodAnnotation] = "runtime/default"
+		}
+		taskRun.Spec = tekton.TaskRunSpec{
 			ServiceAccountName: ServiceAccountName(c),
-			TaskRef: &v1alpha1.TaskRef{
+			Timeout:            &metav1.Duration{Duration: timeout},
+			PodTemplate:        podTemplate,
+			TaskRef: &tekton.TaskRef{
 				Name: TaskName(c),
 			},
-			Inputs: v1alpha1.TaskRunInputs{
-				Params: []v1alpha1.Param{
-					// See description of the parameters within the Tasks
-					// We only override parameters here. Defaults are defined within the Tasks
-					{Name: "baseImage", Value: v1alpha1.ArrayOrString{Type: v1alpha1.ParamTypeString, StringVal: baseImage(c)}},
-					{Name: "moduleDirName", Value: v1alpha1.ArrayOrString{Type: v1alpha1.ParamTypeString, StringVal: moduleDirName(c)}},
-					{Name: "contextPath", Value: v1alpha1.ArrayOrString{Type: v1alpha1.ParamTypeString, StringVal: contextPath(c)}},
-				},
-				Resources: []v1alpha1.TaskResourceBinding{{
-					PipelineResourceBinding: v1alpha1.PipelineResourceBinding{
-						Name: "git",
-						ResourceSpec: &v1alpha1.PipelineResourceSpec{
-							Type: "git",
-							Params: []v1alpha1.ResourceParam{
-								{
-									Name:  "revision",
-									Value: gitRevision(c),
-								},
-								{
-									Name:  "url",
-									Value: c.Spec.BuildConfig.URL},
-							},
-						},
-					},
-				}},
-			},
-			Outputs: v1alpha1.TaskRunOutputs{
-				Resources: []v1alpha1.TaskResourceBinding{{
-					PipelineResourceBinding: v1alpha1.PipelineResourceBinding{
-						Name: "image",
-						ResourceSpec: &v1alpha1.PipelineResourceSpec{
-							Type: "image",
-							Params: []v1alpha1.ResourceParam{
-								{
-									Name: "url",
-									// Calculate the path of the image using docker registry URL for ocp or k8s cluster
-									Value: dockerImageURL(c),
-								},
-							},
-						},
-					},
-				}},
+			// See description of the parameters within the Tasks
+			// We only override parameters here. Defaults are defined within the Tasks
+			Params: append([]tekton.Param{
+				{Name: "url", Value: tekton.NewString(c.Spec.BuildConfig.URL)},
+				{Name: "revision", Value: tekton.NewString(gitRevision(c))},
+				{Name: "commit", Value: tekton.NewString(commit)},
+				{Name: "archive", Value: tekton.NewString(archivePath)},
+				// the image is pushed to the registry configured for the namespace of the component
+				{Name: "image", Value: tekton.NewString(image)},
+				{Name: "tags", Value: tekton.NewArray(buildTags(c)...)},
+				{Name: "verifyTLS", Value: tekton.NewString(strconv.FormatBool(destination.verifyTLS))},
+				{Name: "purgeCache", Value: tekton.NewString(strconv.FormatBool(purge))},
+			}, params...),
+			Workspaces: []tekton.WorkspaceBinding{
+				{Name: sourceWorkspace, EmptyDir: &corev1.EmptyDirVolumeSource{}},
+				buildCacheWorkspace(c),
 			},
 		}
 	}
@@ -91,8 +141,9 @@ func (res taskRun) Build(empty bool) (runtime.Object, error) {
 
 func (res taskRun) GetCondition(underlying runtime.Object, err error) *beta1.DependentCondition {
 	return framework.DefaultCustomizedGetConditionFor(res, err, underlying, func(underlying runtime.Object, cond *beta1.DependentCondition) {
-		tr := underlying.(*v1alpha1.TaskRun)
+		tr := underlying.(*tekton.TaskRun)
 		succeeded := tr.Status.GetCondition(apis.ConditionSucceeded)
+		setBuildAttributes(res.ownerAsComponent(), tr, cond)
 		if succeeded != nil {
 			cond.Message = succeeded.Message
 			cond.Reason = succeeded.Reason
@@ -102,6 +153,22 @@ func (res taskRun) GetCondition(underlying runtime.Object, err error) *beta1.Dep
 			}
 			if succeeded.IsFalse() {
 				cond.Type = beta1.DependentFailed
+				if failure := buildFailure(tr); failure != nil && len(failure.Step) > 0 {
+					cond.SetAttribute(FailedStepAttributeKey, failure.Step)
+					cond.SetAttribute(ExitCodeAttributeKey, strconv.Itoa(int(failure.ExitCode)))
+					if len(failure.Log) > 0 {
+						cond.SetAttribute(LogAttributeKey, failure.Log)
+					}
+				}
+				switch {
+				case isCancelled(tr):
+					cond.Reason = BuildCancelledReason
+				case isTimedOut(tr):
+					cond.Reason = BuildTimedOutReason
+				case isGitAuthenticationFailure(tr):
+					cond.Reason = GitAuthenticationFailedReason
+					cond.Message = gitAuthenticationFailureMessage(res.ownerAsComponent())
+				}
 				return
 			}
 		}
@@ -109,3 +176,46 @@ func (res taskRun) GetCondition(underlying runtime.Object, err error) *beta1.Dep
 		cond.Message = fmt.Sprintf("%s is not ready", tr.Name)
 	})
 }
+
+// setBuildAttributes records the summary of the specified build, along with the history of the builds of the component,
+// as attributes of the specified condition
+func setBuildAttributes(c *v1beta1.Component, tr *tekton.TaskRun, cond *beta1.DependentCondition) {
+	record := newBuildRecord(tr)
+	cond.SetAttribute(BuildNumberAttributeKey, strconv.Itoa(record.Number))
+	cond.SetAttribute(BuildAttemptAttributeKey, strconv.Itoa(record.Attempt))
+	cond.SetAttribute(RefAttributeKey, record.Ref)
+	cond.SetAttribute(BuildResultAttributeKey, record.Result)
+	cond.SetAttribute(PodSecurityLevelAttributeKey, buildPodSecurityLevel(c))
+	if len(record.Commit) > 0 {
+		cond.SetAttribute(CommitAttributeKey, record.Commit)
+	}
+	if len(record.ImageDigest) > 0 {
+		cond.SetAttribute(ImageDigestAttributeKey, record.ImageDigest)
+	}
+	if len(record.SourceDigest) > 0 {
+		cond.SetAttribute(SourceDigestAttributeKey, record.SourceDigest)
+	}
+	builds, err := listBuilds(c)
+	if err != nil {
+		return
+	}
+	limit, err := buildHistoryLimit(c)
+	if err != nil {
+		return
+	}
+	kept, _ := keptBuilds(c, builds, limit)
+	if history, err := json.Marshal(buildRecords(kept)); err == nil {
+		cond.SetAttribute(BuildHistoryAttributeKey, string(history))
+	}
+}
+
+func isSuccessful(tr *tekton.TaskRun) bool {
+	succeeded := tr.Status.GetCondition(apis.ConditionSucceeded)
+	return succeeded != nil && succeeded.IsTrue()
+}
+
+// imageDigest returns the digest of the image the specified TaskRun produced, if recorded as a result
+func imageDigest(tr *tekton.TaskRun) string {
+	digest, _ := tr.Status.Result(imageDigestResult)
+	return strings.TrimSpace(digest)
+}
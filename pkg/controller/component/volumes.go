@@ -0,0 +1,73 @@
+package component
+
+import (
+	"fmt"
+	component "halkyon.io/api/component/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"path"
+	"strings"
+)
+
+const (
+	sharedDataVolumeName = "shared-data"
+	supervisordMountPath = "/var/lib/supervisord"
+)
+
+// storageMountPaths lists where the component's storage is mounted in dev mode
+var storageMountPaths = []string{"/deployments", "/usr/src", "/tmp/artefacts"}
+
+// getUserVolumes returns the additional volumes and associated mounts the component declares using the VolumesAnnotation
+// and VolumeMountsAnnotation, checking that they don't collide with the ones the operator manages
+func getUserVolumes(c *component.Component) ([]corev1.Volume, []corev1.VolumeMount, error) {
+	var volumes []corev1.Volume
+	if _, err := decodeAnnotation(c, VolumesAnnotation, &volumes); err != nil {
+		return nil, nil, err
+	}
+	var mounts []corev1.VolumeMount
+	if _, err := decodeAnnotation(c, VolumeMountsAnnotation, &mounts); err != nil {
+		return nil, nil, err
+	}
+
+	reservedNames := map[string]bool{sharedDataVolumeName: true, PVCName(c): true}
+	names := make(map[string]bool, len(volumes))
+	for _, volume := range volumes {
+		if len(volume.Name) == 0 {
+			return nil, nil, fmt.Errorf("volume declared in '%s' annotation must provide a name", VolumesAnnotation)
+		}
+		if reservedNames[volume.Name] {
+			return nil, nil, fmt.Errorf("'%s' volume name is reserved by the operator", volume.Name)
+		}
+		if names[volume.Name] {
+			return nil, nil, fmt.Errorf("'%s' volume is declared several times", volume.Name)
+		}
+		names[volume.Name] = true
+	}
+
+	reservedPaths := append([]string{supervisordMountPath}, storageMountPaths...)
+	paths := make(map[string]bool, len(mounts))
+	for _, mount := range mounts {
+		if !names[mount.Name] {
+			return nil, nil, fmt.Errorf("'%s' mount refers to unknown '%s' volume", mount.MountPath, mount.Name)
+		}
+		clean := path.Clean(mount.MountPath)
+		if !path.IsAbs(clean) {
+			return nil, nil, fmt.Errorf("'%s' mount path must be absolute", mount.MountPath)
+		}
+		for _, reserved := range reservedPaths {
+			if overlaps(clean, reserved) {
+				return nil, nil, fmt.Errorf("'%s' mount path collides with the '%s' mount managed by the operator", mount.MountPath, reserved)
+			}
+		}
+		if paths[clean] {
+			return nil, nil, fmt.Errorf("'%s' mount path is used several times", mount.MountPath)
+		}
+		paths[clean] = true
+	}
+
+	return volumes, mounts, nil
+}
+
+// overlaps returns whether the specified clean absolute paths are the same or one contains the other
+func overlaps(a, b string) bool {
+	return a == b || a == "/" || b == "/" || strings.HasPrefix(a, b+"/") || strings.HasPrefix(b, a+"/")
+}
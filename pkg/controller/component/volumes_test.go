@@ -0,0 +1,112 @@
+package component
+
+import (
+	"testing"
+)
+
+func TestGetUserVolumes(t *testing.T) {
+	c := newTestComponent(map[string]string{
+		VolumesAnnotation:      `[{"name": "config", "configMap": {"name": "backend-config"}}, {"name": "cache", "emptyDir": {}}]`,
+		VolumeMountsAnnotation: `[{"name": "config", "mountPath": "/config", "readOnly": true}, {"name": "cache", "mountPath": "/cache"}]`,
+	})
+	volumes, mounts, err := getUserVolumes(c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(volumes) != 2 || volumes[0].ConfigMap == nil || volumes[0].ConfigMap.Name != "backend-config" || volumes[1].EmptyDir == nil {
+		t.Errorf("unexpected volumes: %+v", volumes)
+	}
+	if len(mounts) != 2 || mounts[0].MountPath != "/config" || !mounts[0].ReadOnly || mounts[1].MountPath != "/cache" {
+		t.Errorf("unexpected mounts: %+v", mounts)
+	}
+
+	// paths merely sharing a prefix with the ones managed by the operator, or nested in other user mounts, are accepted
+	c.Annotations[VolumeMountsAnnotation] = `[{"name": "config", "mountPath": "/deployments-config"}, {"name": "cache", "mountPath": "/deployments-config/cache"}]`
+	if _, mounts, err := getUserVolumes(c); err != nil || len(mounts) != 2 {
+		t.Errorf("expected the mounts to be accepted, got %+v: %v", mounts, err)
+	}
+
+	if volumes, mounts, err := getUserVolumes(newTestComponent(nil)); err != nil || len(volumes) != 0 || len(mounts) != 0 {
+		t.Errorf("expected no volumes by default, got %+v and %+v: %v", volumes, mounts, err)
+	}
+}
+
+func TestGetInvalidUserVolumes(t *testing.T) {
+	tests := []struct {
+		name    string
+		volumes string
+		mounts  string
+	}{
+		{name: "shared data volume", volumes: `[{"name": "shared-data", "emptyDir": {}}]`},
+		{name: "storage volume", volumes: `[{"name": "m2-data-backend", "emptyDir": {}}]`},
+		{name: "unnamed volume", volumes: `[{"emptyDir": {}}]`},
+		{name: "duplicate volume", volumes: `[{"name": "cache", "emptyDir": {}}, {"name": "cache", "emptyDir": {}}]`},
+		{name: "unknown field", volumes: `[{"name": "cache", "emptyDirectory": {}}]`},
+		{
+			name:    "unknown volume",
+			volumes: `[{"name": "cache", "emptyDir": {}}]`,
+			mounts:  `[{"name": "config", "mountPath": "/config"}]`,
+		},
+		{
+			name:    "supervisord mount path",
+			volumes: `[{"name": "cache", "emptyDir": {}}]`,
+			mounts:  `[{"name": "cache", "mountPath": "/var/lib/supervisord"}]`,
+		},
+		{
+			name:    "storage mount path",
+			volumes: `[{"name": "cache", "emptyDir": {}}]`,
+			mounts:  `[{"name": "cache", "mountPath": "/deployments"}]`,
+		},
+		{
+			name:    "duplicate mount path",
+			volumes: `[{"name": "cache", "emptyDir": {}}, {"name": "config", "emptyDir": {}}]`,
+			mounts:  `[{"name": "cache", "mountPath": "/data"}, {"name": "config", "mountPath": "/data"}]`,
+		},
+		{
+			name:    "storage mount path with trailing slash",
+			volumes: `[{"name": "cache", "emptyDir": {}}]`,
+			mounts:  `[{"name": "cache", "mountPath": "/deployments/"}]`,
+		},
+		{
+			name:    "unclean storage mount path",
+			volumes: `[{"name": "cache", "emptyDir": {}}]`,
+			mounts:  `[{"name": "cache", "mountPath": "/deployments/../deployments"}]`,
+		},
+		{
+			name:    "within storage mount path",
+			volumes: `[{"name": "cache", "emptyDir": {}}]`,
+			mounts:  `[{"name": "cache", "mountPath": "/usr/src/app"}]`,
+		},
+		{
+			name:    "containing supervisord mount path",
+			volumes: `[{"name": "cache", "emptyDir": {}}]`,
+			mounts:  `[{"name": "cache", "mountPath": "/var/lib"}]`,
+		},
+		{
+			name:    "root mount path",
+			volumes: `[{"name": "cache", "emptyDir": {}}]`,
+			mounts:  `[{"name": "cache", "mountPath": "/"}]`,
+		},
+		{
+			name:    "relative mount path",
+			volumes: `[{"name": "cache", "emptyDir": {}}]`,
+			mounts:  `[{"name": "cache", "mountPath": "data"}]`,
+		},
+		{
+			name:    "unclean duplicate mount path",
+			volumes: `[{"name": "cache", "emptyDir": {}}, {"name": "config", "emptyDir": {}}]`,
+			mounts:  `[{"name": "cache", "mountPath": "/data"}, {"name": "config", "mountPath": "/data/"}]`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			annotations := map[string]string{VolumesAnnotation: test.volumes}
+			if len(test.mounts) > 0 {
+				annotations[VolumeMountsAnnotation] = test.mounts
+			}
+			if volumes, mounts, err := getUserVolumes(newTestComponent(annotations)); err == nil {
+				t.Errorf("expected the volumes to be rejected, got %+v and %+v", volumes, mounts)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package git
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+)
+
+// Provider identifies the git host which sent a webhook
+type Provider string
+
+const (
+	GitHub  Provider = "github"
+	GitLab  Provider = "gitlab"
+	Gitea   Provider = "gitea"
+	Generic Provider = "generic"
+
+	zeroSHA = "0000000000000000000000000000000000000000"
+)
+
+// PushEvent describes a push to a repository, as notified by a webhook
+type PushEvent struct {
+	Provider Provider
+	// URLs lists the URLs of the pushed repository
+	URLs []string
+	// Ref is the pushed ref, e.g. refs/heads/main
+	Ref string
+	// Commit is the SHA of the commit the ref now points to
+	Commit string
+}
+
+// pushPayload holds the fields of interest of GitHub, GitLab and Gitea push payloads
+type pushPayload struct {
+	Ref         string `json:"ref"`
+	After       string `json:"after"`
+	CheckoutSHA string `json:"checkout_sha"`
+	Deleted     bool   `json:"deleted"`
+	Repository  struct {
+		URL        string `json:"url"`
+		CloneURL   string `json:"clone_url"`
+		HTMLURL    string `json:"html_url"`
+		GitURL     string `json:"git_url"`
+		SSHURL     string `json:"ssh_url"`
+		Homepage   string `json:"homepage"`
+		GitHTTPURL string `json:"git_http_url"`
+		GitSSHURL  string `json:"git_ssh_url"`
+	} `json:"repository"`
+	Project struct {
+		WebURL     string `json:"web_url"`
+		GitHTTPURL string `json:"git_http_url"`
+		GitSSHURL  string `json:"git_ssh_url"`
+	} `json:"project"`
+}
+
+// ProviderFor identifies the git host which sent a webhook from its headers
+func ProviderFor(header http.Header) Provider {
+	switch {
+	case len(header.Get("X-Gitea-Event")) > 0:
+		// Gitea also sends GitHub headers for compatibility so it needs to be checked first
+		return Gitea
+	case len(header.Get("X-GitHub-Event")) > 0:
+		return GitHub
+	case len(header.Get("X-Gitlab-Event")) > 0:
+		return GitLab
+	default:
+		return Generic
+	}
+}
+
+// ParsePushEvent parses the specified webhook payload, returning nil if it doesn't notify a push, e.g. GitHub ping events
+// or branch deletions
+func ParsePushEvent(header http.Header, body []byte) (*PushEvent, error) {
+	provider := ProviderFor(header)
+	switch provider {
+	case GitHub:
+		if header.Get("X-GitHub-Event") != "push" {
+			return nil, nil
+		}
+	case Gitea:
+		if header.Get("X-Gitea-Event") != "push" {
+			return nil, nil
+		}
+	case GitLab:
+		if event := header.Get("X-Gitlab-Event"); event != "Push Hook" && event != "Tag Push Hook" {
+			return nil, nil
+		}
+	}
+
+	payload := pushPayload{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("invalid %s push payload: %s", provider, err.Error())
+	}
+	if len(payload.Ref) == 0 {
+		if provider == Generic {
+			// not a push event
+			return nil, nil
+		}
+		return nil, fmt.Errorf("invalid %s push payload: missing ref", provider)
+	}
+	commit := payload.After
+	if len(payload.CheckoutSHA) > 0 {
+		commit = payload.CheckoutSHA
+	}
+	if payload.Deleted || len(commit) == 0 || commit == zeroSHA {
+		return nil, nil
+	}
+
+	event := &PushEvent{Provider: provider, Ref: payload.Ref, Commit: commit}
+	repository, project := payload.Repository, payload.Project
+	for _, url := range []string{repository.CloneURL, repository.HTMLURL, repository.GitURL, repository.SSHURL, repository.URL,
+		repository.Homepage, repository.GitHTTPURL, repository.GitSSHURL, project.WebURL, project.GitHTTPURL, project.GitSSHURL} {
+		if len(url) > 0 {
+			event.URLs = append(event.URLs, url)
+		}
+	}
+	if len(event.URLs) == 0 {
+		return nil, fmt.Errorf("invalid %s push payload: missing repository URL", provider)
+	}
+	return event, nil
+}
+
+// Matches returns whether the push updated the specified ref of the repository identified by the specified URL. The ref
+// is either fully qualified, e.g. refs/tags/v1.0, or the name of a branch, so that pushing a tag named after a branch
+// doesn't match it.
+func (e *PushEvent) Matches(url, ref string) bool {
+	if QualifiedRef(e.Ref) != QualifiedRef(ref) {
+		return false
+	}
+	for _, pushed := range e.URLs {
+		if SameRepository(url, pushed) {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifySignature checks that the webhook payload was sent by a git host knowing the specified secret: GitLab sends the
+// secret as is while the other providers send an HMAC of the payload
+func VerifySignature(header http.Header, body []byte, secret string) bool {
+	if len(secret) == 0 {
+		return false
+	}
+	switch ProviderFor(header) {
+	case GitLab:
+		token := header.Get("X-Gitlab-Token")
+		return subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+	case Gitea:
+		if signature := header.Get("X-Gitea-Signature"); len(signature) > 0 {
+			return validHMAC(sha256.New, body, secret, signature)
+		}
+	}
+	if signature := header.Get("X-Hub-Signature-256"); len(signature) > 0 {
+		return strings.HasPrefix(signature, "sha256=") && validHMAC(sha256.New, body, secret, strings.TrimPrefix(signature, "sha256="))
+	}
+	if signature := header.Get("X-Hub-Signature"); len(signature) > 0 {
+		return strings.HasPrefix(signature, "sha1=") && validHMAC(sha1.New, body, secret, strings.TrimPrefix(signature, "sha1="))
+	}
+	return false
+}
+
+func validHMAC(hashFn func() hash.Hash, body []byte, secret, signature string) bool {
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(hashFn, []byte(secret))
+	_, _ = mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
@@ -0,0 +1,125 @@
+package git
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const maxAdvertisementSize = 10 * 1024 * 1024
+
+var sha = regexp.MustCompile("^[0-9a-f]{40}$")
+
+// IsCommitSHA returns whether the specified ref is a full commit SHA
+func IsCommitSHA(ref string) bool {
+	return sha.MatchString(ref)
+}
+
+// LsRemote lists the refs of the specified remote repository along with the SHA they point to, like git ls-remote does,
+// using the git HTTP protocol. Only HTTP(S) repository URLs are supported. Credentials, if any, are sent using basic
+// authentication.
+func LsRemote(client *http.Client, url, username, password string) (map[string]string, error) {
+	if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+		return nil, fmt.Errorf("unsupported '%s' repository URL: only HTTP(S) repositories can be queried", url)
+	}
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(url, "/")+"/info/refs?service=git-upload-pack", nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(username) > 0 || len(password) > 0 {
+		req.SetBasicAuth(username, password)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s repository answered %d when listing its refs", NormalizeURL(url), resp.StatusCode)
+	}
+
+	body := io.LimitReader(resp.Body, maxAdvertisementSize)
+	if resp.Header.Get("Content-Type") == "application/x-git-upload-pack-advertisement" {
+		return parseAdvertisement(body)
+	}
+	// servers not supporting the smart protocol serve the info/refs file as is
+	return parseInfoRefs(body)
+}
+
+// parseAdvertisement parses the pkt-line encoded refs advertised by smart HTTP servers
+func parseAdvertisement(body io.Reader) (map[string]string, error) {
+	refs := make(map[string]string)
+	reader := bufio.NewReader(body)
+	for {
+		size := make([]byte, 4)
+		if _, err := io.ReadFull(reader, size); err != nil {
+			if err == io.EOF {
+				return refs, nil
+			}
+			return nil, fmt.Errorf("invalid refs advertisement: %s", err.Error())
+		}
+		length, err := strconv.ParseUint(string(size), 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid refs advertisement: bad pkt-line length '%s'", size)
+		}
+		if length < 4 {
+			// flush packet
+			continue
+		}
+		line := make([]byte, length-4)
+		if _, err := io.ReadFull(reader, line); err != nil {
+			return nil, fmt.Errorf("invalid refs advertisement: %s", err.Error())
+		}
+		text := strings.TrimSuffix(string(line), "\n")
+		if strings.HasPrefix(text, "#") {
+			// service announcement
+			continue
+		}
+		if i := strings.IndexByte(text, 0); i >= 0 {
+			// capabilities are sent after the first ref
+			text = text[:i]
+		}
+		if fields := strings.Fields(text); len(fields) == 2 && IsCommitSHA(fields[0]) {
+			refs[fields[1]] = fields[0]
+		}
+	}
+}
+
+// parseInfoRefs parses the tab-separated refs listed by the info/refs file of dumb HTTP servers
+func parseInfoRefs(body io.Reader) (map[string]string, error) {
+	content, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	refs := make(map[string]string)
+	for _, line := range strings.Split(string(content), "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 && IsCommitSHA(fields[0]) {
+			refs[fields[1]] = fields[0]
+		}
+	}
+	if len(refs) == 0 {
+		return nil, fmt.Errorf("no refs found, is it a git repository?")
+	}
+	return refs, nil
+}
+
+// ResolveRef returns the SHA of the commit the specified ref points to among the listed refs. The ref can be a full ref,
+// a branch name, HEAD or a commit SHA, which resolves to itself. As when matching push events, a name without refs/
+// prefix is the name of a branch, tags being designated by their full ref.
+func ResolveRef(refs map[string]string, ref string) (string, bool) {
+	if IsCommitSHA(ref) {
+		return ref, true
+	}
+	ref = QualifiedRef(ref)
+	// annotated tags are peeled to the commit they point to
+	if commit, found := refs[ref+"^{}"]; found {
+		return commit, true
+	}
+	commit, found := refs[ref]
+	return commit, found
+}
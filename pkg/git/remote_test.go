@@ -0,0 +1,87 @@
+package git
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const (
+	masterSHA = "0d1a26e67d8f5eaf1f6ba5c57fc3c7d91ac0fd1c"
+	tagSHA    = "9049f1265b7d61be4a8904a9a27120d2064dab3b"
+	peeledSHA = "da1560886d4f094c3e6c9ef40349f7d38b5d27d7"
+)
+
+func pktLine(line string) string {
+	return fmt.Sprintf("%04x%s", len(line)+4, line)
+}
+
+func TestLsRemote(t *testing.T) {
+	advertisement := pktLine("# service=git-upload-pack\n") + "0000" +
+		pktLine(masterSHA+" HEAD\x00multi_ack thin-pack side-band symref=HEAD:refs/heads/master\n") +
+		pktLine(masterSHA+" refs/heads/master\n") +
+		pktLine(tagSHA+" refs/tags/v1.0.0\n") +
+		pktLine(peeledSHA+" refs/tags/v1.0.0^{}\n") +
+		"0000"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/acme/rest-http.git/info/refs" || r.URL.Query().Get("service") != "git-upload-pack" {
+			http.NotFound(w, r)
+			return
+		}
+		if user, password, ok := r.BasicAuth(); !ok || user != "user" || password != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-git-upload-pack-advertisement")
+		_, _ = w.Write([]byte(advertisement))
+	}))
+	defer server.Close()
+
+	if _, err := LsRemote(server.Client(), server.URL+"/acme/rest-http.git", "", ""); err == nil {
+		t.Fatal("expected anonymous listing to be rejected")
+	}
+	refs, err := LsRemote(server.Client(), server.URL+"/acme/rest-http.git", "user", "token")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for ref, expected := range map[string]string{
+		"master":            masterSHA,
+		"refs/heads/master": masterSHA,
+		"HEAD":              masterSHA,
+		"refs/tags/v1.0.0":  peeledSHA,
+		tagSHA:              tagSHA,
+	} {
+		if commit, found := ResolveRef(refs, ref); !found || commit != expected {
+			t.Errorf("expected %s to resolve to %s, got %s", ref, expected, commit)
+		}
+	}
+	if _, found := ResolveRef(refs, "develop"); found {
+		t.Error("expected unknown branch not to resolve")
+	}
+	if _, found := ResolveRef(refs, "v1.0.0"); found {
+		t.Error("expected a tag name without refs/tags/ prefix not to resolve, as when matching push events")
+	}
+}
+
+func TestLsRemoteDumbServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte(masterSHA + "\trefs/heads/master\n" + tagSHA + "\trefs/tags/v1.0.0\n"))
+	}))
+	defer server.Close()
+
+	refs, err := LsRemote(server.Client(), server.URL+"/repo.git", "", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if commit, _ := ResolveRef(refs, "master"); commit != masterSHA {
+		t.Errorf("expected master to resolve to %s, got %s", masterSHA, commit)
+	}
+}
+
+func TestLsRemoteRejectsSSH(t *testing.T) {
+	if _, err := LsRemote(http.DefaultClient, "git@github.com:halkyonio/operator.git", "", ""); err == nil {
+		t.Error("expected SSH URL to be rejected")
+	}
+}
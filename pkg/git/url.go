@@ -0,0 +1,82 @@
+package git
+
+import (
+	"strings"
+)
+
+// NormalizeURL reduces the specified repository URL to a host/path form so that the HTTP(S), git and SSH URLs of a
+// repository can be compared: scheme, credentials, port, .git suffix and trailing slash are dropped and the host is
+// lower-cased
+func NormalizeURL(url string) string {
+	url = strings.TrimSpace(url)
+	if i := strings.Index(url, "://"); i >= 0 {
+		url = url[i+3:]
+	} else if i := strings.Index(url, ":"); i >= 0 && !strings.Contains(url[:i], "/") {
+		// scp-like SSH syntax: git@host:path
+		url = url[:i] + "/" + url[i+1:]
+	}
+	if i := strings.Index(url, "@"); i >= 0 && i < strings.Index(url+"/", "/") {
+		url = url[i+1:]
+	}
+	host, path := url, ""
+	if i := strings.Index(url, "/"); i >= 0 {
+		host, path = url[:i], url[i:]
+	}
+	if i := strings.Index(host, ":"); i >= 0 {
+		host = host[:i]
+	}
+	path = strings.TrimSuffix(strings.TrimSuffix(path, "/"), ".git")
+	return strings.ToLower(host) + path
+}
+
+// SameRepository returns whether the specified URLs identify the same repository
+func SameRepository(url, other string) bool {
+	return len(url) > 0 && NormalizeURL(url) == NormalizeURL(other)
+}
+
+// ShortRef returns the name of the branch or tag identified by the specified ref, e.g. main for refs/heads/main
+func ShortRef(ref string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(ref, "refs/heads/"), "refs/tags/")
+}
+
+// QualifiedRef returns the fully qualified form of the specified ref, a name without refs/ prefix being the name of a
+// branch, e.g. refs/heads/main for main, so that tags are only designated by their full ref. HEAD is returned as is.
+func QualifiedRef(ref string) string {
+	if strings.HasPrefix(ref, "refs/") || ref == "HEAD" {
+		return ref
+	}
+	return "refs/heads/" + ref
+}
+
+// Scheme returns the scheme of the specified repository URL, ssh for the scp-like SSH syntax, e.g. git@host:path
+func Scheme(url string) string {
+	url = strings.TrimSpace(url)
+	if i := strings.Index(url, "://"); i >= 0 {
+		return strings.ToLower(url[:i])
+	}
+	if i := strings.Index(url, ":"); i >= 0 && !strings.Contains(url[:i], "/") {
+		return "ssh"
+	}
+	return ""
+}
+
+// Host returns the host of the specified repository URL, including its port if specified, without credentials
+func Host(url string) string {
+	url = strings.TrimSpace(url)
+	scpLike := false
+	if i := strings.Index(url, "://"); i >= 0 {
+		url = url[i+3:]
+	} else {
+		scpLike = Scheme(url) == "ssh"
+	}
+	if i := strings.Index(url, "/"); i >= 0 {
+		url = url[:i]
+	}
+	if i := strings.LastIndex(url, "@"); i >= 0 {
+		url = url[i+1:]
+	}
+	if i := strings.Index(url, ":"); scpLike && i >= 0 {
+		url = url[:i]
+	}
+	return strings.ToLower(url)
+}
@@ -0,0 +1,91 @@
+package registry
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	dockerHub         = "docker.io"
+	dockerHubRegistry = "registry-1.docker.io"
+	defaultTag        = "latest"
+)
+
+// Reference identifies an image in a registry, either by tag or by digest
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseReference parses the specified image reference, applying the usual defaults: images without registry are looked up
+// on Docker Hub and images without tag nor digest use the latest tag
+func ParseReference(image string) (Reference, error) {
+	ref := Reference{}
+	remainder := image
+	if i := strings.Index(remainder, "@"); i >= 0 {
+		ref.Digest = remainder[i+1:]
+		remainder = remainder[:i]
+		if !strings.Contains(ref.Digest, ":") {
+			return Reference{}, fmt.Errorf("invalid digest in '%s' image reference", image)
+		}
+	}
+	// a tag is separated by the last colon, provided it's not part of the registry host:port
+	if i := strings.LastIndex(remainder, ":"); i > strings.LastIndex(remainder, "/") {
+		ref.Tag = remainder[i+1:]
+		remainder = remainder[:i]
+	}
+	// the first path component is a registry if it looks like a host
+	if i := strings.Index(remainder, "/"); i > 0 && (strings.ContainsAny(remainder[:i], ".:") || remainder[:i] == "localhost") {
+		ref.Registry = remainder[:i]
+		ref.Repository = remainder[i+1:]
+	} else {
+		ref.Registry = dockerHub
+		ref.Repository = remainder
+	}
+	if len(ref.Repository) == 0 {
+		return Reference{}, fmt.Errorf("invalid '%s' image reference: missing repository", image)
+	}
+	if ref.Registry == dockerHub && !strings.Contains(ref.Repository, "/") {
+		ref.Repository = "library/" + ref.Repository
+	}
+	if len(ref.Tag) == 0 && len(ref.Digest) == 0 {
+		ref.Tag = defaultTag
+	}
+	return ref, nil
+}
+
+// Name returns the image name, i.e. the reference without tag nor digest
+func (in Reference) Name() string {
+	return in.Registry + "/" + in.Repository
+}
+
+// WithDigest returns the reference pinned to the specified digest
+func (in Reference) WithDigest(digest string) string {
+	return in.Name() + "@" + digest
+}
+
+// String returns the full reference, preferring the digest over the tag
+func (in Reference) String() string {
+	if len(in.Digest) > 0 {
+		return in.WithDigest(in.Digest)
+	}
+	return in.Name() + ":" + in.Tag
+}
+
+// host returns the host serving the registry API
+func (in Reference) host() string {
+	if in.Registry == dockerHub {
+		return dockerHubRegistry
+	}
+	return in.Registry
+}
+
+// manifestReference returns what identifies the manifest in the registry API
+func (in Reference) manifestReference() string {
+	if len(in.Digest) > 0 {
+		return in.Digest
+	}
+	return in.Tag
+}
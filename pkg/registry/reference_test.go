@@ -0,0 +1,128 @@
+package registry
+
+import "testing"
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		image      string
+		registry   string
+		repository string
+		tag        string
+		digest     string
+		name       string
+		host       string
+	}{
+		{
+			image:      "nginx",
+			registry:   "docker.io",
+			repository: "library/nginx",
+			tag:        "latest",
+			name:       "docker.io/library/nginx",
+			host:       "registry-1.docker.io",
+		},
+		{
+			image:      "halkyonio/operator:v1",
+			registry:   "docker.io",
+			repository: "halkyonio/operator",
+			tag:        "v1",
+			name:       "docker.io/halkyonio/operator",
+			host:       "registry-1.docker.io",
+		},
+		{
+			image:      "quay.io/halkyonio/spring-boot-maven-s2i",
+			registry:   "quay.io",
+			repository: "halkyonio/spring-boot-maven-s2i",
+			tag:        "latest",
+			name:       "quay.io/halkyonio/spring-boot-maven-s2i",
+			host:       "quay.io",
+		},
+		{
+			image:      "localhost/app",
+			registry:   "localhost",
+			repository: "app",
+			tag:        "latest",
+			name:       "localhost/app",
+			host:       "localhost",
+		},
+		{
+			image:      "localhost:5000/app",
+			registry:   "localhost:5000",
+			repository: "app",
+			tag:        "latest",
+			name:       "localhost:5000/app",
+			host:       "localhost:5000",
+		},
+		{
+			image:      "kube-registry.kube-system.svc:5000/demo/fruit-backend:build-3",
+			registry:   "kube-registry.kube-system.svc:5000",
+			repository: "demo/fruit-backend",
+			tag:        "build-3",
+			name:       "kube-registry.kube-system.svc:5000/demo/fruit-backend",
+			host:       "kube-registry.kube-system.svc:5000",
+		},
+		{
+			image:      "registry:5000/demo/app@sha256:0123456789abcdef",
+			registry:   "registry:5000",
+			repository: "demo/app",
+			digest:     "sha256:0123456789abcdef",
+			name:       "registry:5000/demo/app",
+			host:       "registry:5000",
+		},
+		{
+			image:      "quay.io/demo/app:1.0@sha256:0123456789abcdef",
+			registry:   "quay.io",
+			repository: "demo/app",
+			tag:        "1.0",
+			digest:     "sha256:0123456789abcdef",
+			name:       "quay.io/demo/app",
+			host:       "quay.io",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.image, func(t *testing.T) {
+			ref, err := ParseReference(test.image)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ref.Registry != test.registry || ref.Repository != test.repository || ref.Tag != test.tag || ref.Digest != test.digest {
+				t.Errorf("expected %s registry, %s repository, '%s' tag and '%s' digest, got %+v", test.registry, test.repository, test.tag, test.digest, ref)
+			}
+			if ref.Name() != test.name {
+				t.Errorf("expected %s name, got %s", test.name, ref.Name())
+			}
+			if ref.host() != test.host {
+				t.Errorf("expected %s host, got %s", test.host, ref.host())
+			}
+		})
+	}
+}
+
+func TestParseInvalidReference(t *testing.T) {
+	for _, image := range []string{"", "quay.io/", "quay.io/demo/app@0123456789abcdef", ":latest"} {
+		if ref, err := ParseReference(image); err == nil {
+			t.Errorf("expected '%s' to be rejected, got %+v", image, ref)
+		}
+	}
+}
+
+func TestReferenceString(t *testing.T) {
+	tests := map[string]string{
+		"nginx":                     "docker.io/library/nginx:latest",
+		"quay.io/demo/app:1.0":      "quay.io/demo/app:1.0",
+		"quay.io/demo/app@sha256:1": "quay.io/demo/app@sha256:1",
+		// the digest prevails over the tag
+		"quay.io/demo/app:1.0@sha256:1": "quay.io/demo/app@sha256:1",
+	}
+	for image, expected := range tests {
+		ref, err := ParseReference(image)
+		if err != nil {
+			t.Fatalf("unexpected error parsing '%s': %v", image, err)
+		}
+		if ref.String() != expected {
+			t.Errorf("expected '%s' to be %s, got %s", image, expected, ref.String())
+		}
+		if pinned := ref.WithDigest("sha256:2"); pinned != ref.Name()+"@sha256:2" {
+			t.Errorf("unexpected '%s' pinned reference: %s", image, pinned)
+		}
+	}
+}
@@ -0,0 +1,416 @@
+package registry
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var manifestMediaTypes = []string{
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.oci.image.index.v1+json",
+}
+
+// Credentials holds what's needed to authenticate against a registry
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// Keychain returns the credentials to use for the specified registry host, if any
+type Keychain func(registry string) (Credentials, bool)
+
+// TLS describes how the certificate of a registry is verified
+type TLS struct {
+	// Insecure registries are accessed without verifying their certificate, falling back to plain HTTP if needed
+	Insecure bool
+	// CABundle holds the PEM encoded certificates of the authorities trusted in addition to the system ones
+	CABundle string
+}
+
+// Resolver resolves image tags to the digest of the manifest they currently point to using the registry HTTP API
+type Resolver struct {
+	client         *http.Client
+	insecureClient *http.Client
+	// caClients holds the clients trusting additional authorities, by CA bundle
+	caClients map[string]*http.Client
+	timeout   time.Duration
+	ttl       time.Duration
+	// cache holds the resolved digests by image, credentials and certificate verification, see cacheKey
+	cache map[string]cachedDigest
+	mutex sync.Mutex
+}
+
+type cachedDigest struct {
+	digest   string
+	resolved time.Time
+}
+
+type statusError struct {
+	url    string
+	status int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("registry answered %d to %s", e.status, e.url)
+}
+
+// NewResolver creates a Resolver caching the resolved digests for the specified duration
+func NewResolver(ttl time.Duration) *Resolver {
+	timeout := 10 * time.Second
+	return &Resolver{
+		client: &http.Client{Timeout: timeout},
+		insecureClient: &http.Client{Timeout: timeout, Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}},
+		caClients: make(map[string]*http.Client),
+		timeout:   timeout,
+		ttl:       ttl,
+		cache:     make(map[string]cachedDigest),
+	}
+}
+
+// Digest returns the digest of the manifest the specified image currently points to, verifying the certificate of the
+// registry as specified
+func (r *Resolver) Digest(image string, keychain Keychain, verify TLS) (string, error) {
+	return r.DigestSince(image, keychain, verify, time.Time{})
+}
+
+// DigestSince behaves like Digest but ignores digests resolved before the specified time, e.g. the digest an image tag
+// pointed to before a new image got pushed
+func (r *Resolver) DigestSince(image string, keychain Keychain, verify TLS, since time.Time) (string, error) {
+	ref, err := ParseReference(image)
+	if err != nil {
+		return "", err
+	}
+	if len(ref.Digest) > 0 {
+		return ref.Digest, nil
+	}
+
+	key := cacheKey(ref, keychain, verify)
+	r.mutex.Lock()
+	cached, found := r.cache[key]
+	r.mutex.Unlock()
+	if found && cached.resolved.After(since) && time.Now().Before(cached.resolved.Add(r.ttl)) {
+		return cached.digest, nil
+	}
+
+	client, schemes, err := r.clientFor(verify)
+	if err != nil {
+		return "", err
+	}
+	for _, scheme := range schemes {
+		var digest string
+		digest, err = r.manifestDigest(client, scheme, ref, keychain)
+		if err == nil {
+			r.mutex.Lock()
+			r.cache[key] = cachedDigest{digest: digest, resolved: time.Now()}
+			r.mutex.Unlock()
+			return digest, nil
+		}
+		if _, ok := err.(*statusError); ok {
+			// the registry answered: no need to try another scheme
+			break
+		}
+	}
+	return "", fmt.Errorf("couldn't resolve digest of '%s' image: %s", image, err.Error())
+}
+
+// Tag points the specified tag of the repository of the specified image to the manifest the image points to, returning
+// the digest of this manifest. No layer is copied as the layers of the image already belong to the repository.
+func (r *Resolver) Tag(image, tag string, keychain Keychain, verify TLS) (string, error) {
+	ref, err := ParseReference(image)
+	if err != nil {
+		return "", err
+	}
+	client, schemes, err := r.clientFor(verify)
+	if err != nil {
+		return "", err
+	}
+	for _, scheme := range schemes {
+		var digest string
+		digest, err = r.retag(client, scheme, ref, tag, keychain)
+		if err == nil {
+			tagged := ref
+			tagged.Tag, tagged.Digest = tag, ""
+			r.mutex.Lock()
+			r.cache[cacheKey(tagged, keychain, verify)] = cachedDigest{digest: digest, resolved: time.Now()}
+			r.mutex.Unlock()
+			return digest, nil
+		}
+		if _, ok := err.(*statusError); ok {
+			break
+		}
+	}
+	return "", fmt.Errorf("couldn't tag '%s' image as '%s': %s", image, tag, err.Error())
+}
+
+// cacheKey identifies the digest of the specified image resolved using the credentials the specified keychain provides
+// for its registry and the specified verification of its certificate, so that it's only returned from the cache to the
+// callers which would resolve it the same way, e.g. not to the ones lacking the credentials of a private repository
+func cacheKey(ref Reference, keychain Keychain, verify TLS) string {
+	hash := sha256.New()
+	if keychain != nil {
+		if credentials, found := keychain(ref.Registry); found {
+			_, _ = fmt.Fprintf(hash, "%q:%q\n", credentials.Username, credentials.Password)
+		}
+	}
+	_, _ = fmt.Fprintf(hash, "%t\n%s", verify.Insecure, verify.CABundle)
+	return fmt.Sprintf("%s#%x", ref.String(), hash.Sum(nil))
+}
+
+// clientFor returns the client verifying the certificate of registries as specified, along with the schemes to try
+func (r *Resolver) clientFor(verify TLS) (*http.Client, []string, error) {
+	if verify.Insecure {
+		return r.insecureClient, []string{"https", "http"}, nil
+	}
+	if len(verify.CABundle) > 0 {
+		client, err := r.caClient(verify.CABundle)
+		return client, []string{"https"}, err
+	}
+	return r.client, []string{"https"}, nil
+}
+
+// caClient returns a client trusting the authorities of the specified CA bundle in addition to the system ones
+func (r *Resolver) caClient(bundle string) (*http.Client, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if client, found := r.caClients[bundle]; found {
+		return client, nil
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM([]byte(bundle)) {
+		return nil, fmt.Errorf("CA bundle doesn't contain any valid PEM encoded certificate")
+	}
+	client := &http.Client{Timeout: r.timeout, Transport: &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{RootCAs: pool},
+	}}
+	r.caClients[bundle] = client
+	return client, nil
+}
+
+func (r *Resolver) manifestDigest(client *http.Client, scheme string, ref Reference, keychain Keychain) (string, error) {
+	manifestURL := fmt.Sprintf("%s://%s/v2/%s/manifests/%s", scheme, ref.host(), ref.Repository, ref.manifestReference())
+	resp, err := r.request(client, http.MethodHead, manifestURL, "")
+	if err != nil {
+		return "", err
+	}
+	authorization := ""
+	if resp.StatusCode == http.StatusUnauthorized {
+		authorization, err = r.authorize(client, resp.Header.Get("Www-Authenticate"), ref, keychain, "pull")
+		if err != nil {
+			return "", err
+		}
+		if resp, err = r.request(client, http.MethodHead, manifestURL, authorization); err != nil {
+			return "", err
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", &statusError{url: manifestURL, status: resp.StatusCode}
+	}
+	if digest := resp.Header.Get("Docker-Content-Digest"); len(digest) > 0 {
+		return digest, nil
+	}
+
+	// the registry doesn't provide the digest as a header: compute it from the manifest
+	resp, err = r.request(client, http.MethodGet, manifestURL, authorization)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", &statusError{url: manifestURL, status: resp.StatusCode}
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, resp.Body); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("sha256:%x", hash.Sum(nil)), nil
+}
+
+// retag uploads the manifest of the specified image under the specified tag
+func (r *Resolver) retag(client *http.Client, scheme string, ref Reference, tag string, keychain Keychain) (string, error) {
+	manifestsURL := fmt.Sprintf("%s://%s/v2/%s/manifests/", scheme, ref.host(), ref.Repository)
+	resp, err := r.request(client, http.MethodGet, manifestsURL+ref.manifestReference(), "")
+	if err != nil {
+		return "", err
+	}
+	authorization := ""
+	if resp.StatusCode == http.StatusUnauthorized {
+		_ = resp.Body.Close()
+		authorization, err = r.authorize(client, resp.Header.Get("Www-Authenticate"), ref, keychain, "pull,push")
+		if err != nil {
+			return "", err
+		}
+		if resp, err = r.request(client, http.MethodGet, manifestsURL+ref.manifestReference(), authorization); err != nil {
+			return "", err
+		}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", &statusError{url: manifestsURL + ref.manifestReference(), status: resp.StatusCode}
+	}
+	manifest, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	put, err := r.upload(client, manifestsURL+tag, contentType, manifest, authorization)
+	if err != nil {
+		return "", err
+	}
+	if put.StatusCode == http.StatusUnauthorized {
+		// registries allowing anonymous pulls only require authentication to push
+		authorization, err = r.authorize(client, put.Header.Get("Www-Authenticate"), ref, keychain, "pull,push")
+		if err != nil {
+			return "", err
+		}
+		if put, err = r.upload(client, manifestsURL+tag, contentType, manifest, authorization); err != nil {
+			return "", err
+		}
+	}
+	if put.StatusCode != http.StatusCreated && put.StatusCode != http.StatusOK {
+		return "", &statusError{url: manifestsURL + tag, status: put.StatusCode}
+	}
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(manifest)), nil
+}
+
+// upload uploads the specified manifest to the specified URL
+func (r *Resolver) upload(client *http.Client, url, contentType string, manifest []byte, authorization string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(manifest))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if len(authorization) > 0 {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close()
+	return resp, nil
+}
+
+func (r *Resolver) request(client *http.Client, method, url, authorization string) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	if len(authorization) > 0 {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if method == http.MethodHead {
+		_ = resp.Body.Close()
+	}
+	return resp, nil
+}
+
+// authorize computes the Authorization header answering the specified challenge, retrieving a token allowing the
+// specified actions on the repository if needed
+func (r *Resolver) authorize(client *http.Client, challenge string, ref Reference, keychain Keychain, actions string) (string, error) {
+	var credentials Credentials
+	hasCredentials := false
+	if keychain != nil {
+		credentials, hasCredentials = keychain(ref.Registry)
+	}
+	basic := ""
+	if hasCredentials {
+		basic = "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials.Username+":"+credentials.Password))
+	}
+
+	scheme, params := parseChallenge(challenge)
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if !hasCredentials {
+			return "", fmt.Errorf("%s registry requires credentials", ref.Registry)
+		}
+		return basic, nil
+	case "bearer":
+		realm, err := url.Parse(params["realm"])
+		if err != nil || len(realm.Host) == 0 {
+			return "", fmt.Errorf("invalid token realm in challenge from %s registry: %s", ref.Registry, challenge)
+		}
+		query := realm.Query()
+		if service, ok := params["service"]; ok {
+			query.Set("service", service)
+		}
+		query.Set("scope", fmt.Sprintf("repository:%s:%s", ref.Repository, actions))
+		realm.RawQuery = query.Encode()
+
+		req, err := http.NewRequest(http.MethodGet, realm.String(), nil)
+		if err != nil {
+			return "", err
+		}
+		if hasCredentials {
+			req.Header.Set("Authorization", basic)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return "", &statusError{url: realm.String(), status: resp.StatusCode}
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return "", err
+		}
+		token := struct {
+			Token       string `json:"token"`
+			AccessToken string `json:"access_token"`
+		}{}
+		if err := json.Unmarshal(body, &token); err != nil {
+			return "", fmt.Errorf("invalid token returned by %s: %s", realm.Host, err.Error())
+		}
+		if len(token.Token) == 0 {
+			token.Token = token.AccessToken
+		}
+		return "Bearer " + token.Token, nil
+	default:
+		return "", fmt.Errorf("unsupported '%s' authentication scheme requested by %s registry", scheme, ref.Registry)
+	}
+}
+
+// parseChallenge parses a WWW-Authenticate header value such as: Bearer realm="https://auth.docker.io/token",service="registry.docker.io"
+func parseChallenge(challenge string) (scheme string, params map[string]string) {
+	params = make(map[string]string)
+	challenge = strings.TrimSpace(challenge)
+	i := strings.IndexRune(challenge, ' ')
+	if i < 0 {
+		return challenge, params
+	}
+	scheme = challenge[:i]
+	for _, param := range strings.Split(challenge[i+1:], ",") {
+		if j := strings.IndexRune(param, '='); j > 0 {
+			params[strings.TrimSpace(param[:j])] = strings.Trim(strings.TrimSpace(param[j+1:]), `"`)
+		}
+	}
+	return scheme, params
+}
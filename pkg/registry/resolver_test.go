@@ -0,0 +1,358 @@
+package registry
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	repository   = "demo/app"
+	manifest     = `{"schemaVersion": 2, "mediaType": "application/vnd.docker.distribution.manifest.v2+json"}`
+	manifestType = "application/vnd.docker.distribution.manifest.v2+json"
+	username     = "builder"
+	password     = "s3cr3t"
+	token        = "t0k3n"
+)
+
+var manifestDigest = fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(manifest)))
+
+// fakeRegistry serves the manifests of a single repository, requiring the configured authentication. Its server must
+// be closed once done.
+type fakeRegistry struct {
+	// auth is the authentication scheme required by the registry: none, basic, bearer or push, which only requires a
+	// bearer token to push
+	auth string
+	// digestHeader sets whether the digest of the manifests is returned as a header
+	digestHeader bool
+	mutex        sync.Mutex
+	tags         map[string]string
+	requests     map[string]int
+	scopes       []string
+	server       *httptest.Server
+}
+
+func newFakeRegistry(auth string, digestHeader bool, tls bool) *fakeRegistry {
+	registry := &fakeRegistry{
+		auth:         auth,
+		digestHeader: digestHeader,
+		tags:         map[string]string{"latest": manifest},
+		requests:     make(map[string]int),
+	}
+	if tls {
+		registry.server = httptest.NewTLSServer(registry)
+	} else {
+		registry.server = httptest.NewServer(registry)
+	}
+	return registry
+}
+
+// image returns the reference of the specified tag of the repository served by the registry
+func (f *fakeRegistry) image(tag string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(f.server.URL, "http://"), "https://") + "/" + repository + ":" + tag
+}
+
+func (f *fakeRegistry) count(method string) int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.requests[method]
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if r.URL.Path == "/token" {
+		if r.Header.Get("Authorization") != basicAuthorization() {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		f.scopes = append(f.scopes, r.URL.Query().Get("scope"))
+		_, _ = fmt.Fprintf(w, `{"token": "%s"}`, token)
+		return
+	}
+
+	switch f.auth {
+	case "basic":
+		if r.Header.Get("Authorization") != basicAuthorization() {
+			w.Header().Set("Www-Authenticate", `Basic realm="fake"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	case "bearer", "push":
+		if r.Header.Get("Authorization") != "Bearer "+token && (f.auth == "bearer" || r.Method == http.MethodPut) {
+			w.Header().Set("Www-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="fake"`, f.server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+	f.requests[r.Method]++
+
+	prefix := "/v2/" + repository + "/manifests/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	tag := strings.TrimPrefix(r.URL.Path, prefix)
+	switch r.Method {
+	case http.MethodHead, http.MethodGet:
+		content, found := f.tags[tag]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", manifestType)
+		if f.digestHeader {
+			w.Header().Set("Docker-Content-Digest", fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(content))))
+		}
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(content))
+		}
+	case http.MethodPut:
+		if r.Header.Get("Content-Type") != manifestType {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		content, _ := ioutil.ReadAll(r.Body)
+		f.tags[tag] = string(content)
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func basicAuthorization() string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+}
+
+func keychain(registry string) (Credentials, bool) {
+	return Credentials{Username: username, Password: password}, true
+}
+
+func TestDigest(t *testing.T) {
+	tests := []struct {
+		name         string
+		auth         string
+		digestHeader bool
+		keychain     Keychain
+		fails        bool
+	}{
+		{name: "anonymous with digest header", auth: "none", digestHeader: true},
+		{name: "anonymous without digest header", auth: "none"},
+		{name: "basic", auth: "basic", digestHeader: true, keychain: keychain},
+		{name: "basic without credentials", auth: "basic", digestHeader: true, fails: true},
+		{name: "bearer", auth: "bearer", digestHeader: true, keychain: keychain},
+		{name: "bearer without digest header", auth: "bearer", keychain: keychain},
+		{name: "bearer without credentials", auth: "bearer", digestHeader: true, fails: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			registry := newFakeRegistry(test.auth, test.digestHeader, false)
+			defer registry.server.Close()
+			digest, err := NewResolver(time.Hour).Digest(registry.image("latest"), test.keychain, TLS{Insecure: true})
+			if test.fails {
+				if err == nil {
+					t.Fatalf("expected resolution to fail, got %s", digest)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if digest != manifestDigest {
+				t.Errorf("expected %s digest, got %s", manifestDigest, digest)
+			}
+			if test.auth == "bearer" && (len(registry.scopes) == 0 || registry.scopes[0] != "repository:"+repository+":pull") {
+				t.Errorf("expected a pull token to be requested, got %v scopes", registry.scopes)
+			}
+		})
+	}
+}
+
+func TestDigestOfMissingTag(t *testing.T) {
+	registry := newFakeRegistry("none", true, false)
+	defer registry.server.Close()
+	if digest, err := NewResolver(time.Hour).Digest(registry.image("missing"), nil, TLS{Insecure: true}); err == nil {
+		t.Errorf("expected resolution to fail, got %s", digest)
+	}
+}
+
+func TestDigestOfPinnedImage(t *testing.T) {
+	// pinned images are not resolved, so no registry is needed
+	digest, err := NewResolver(time.Hour).Digest("localhost:1/demo/app@sha256:1234", nil, TLS{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if digest != "sha256:1234" {
+		t.Errorf("expected sha256:1234 digest, got %s", digest)
+	}
+}
+
+func TestDigestVerifiesCertificates(t *testing.T) {
+	registry := newFakeRegistry("none", true, true)
+	defer registry.server.Close()
+	resolver := NewResolver(time.Hour)
+	if _, err := resolver.Digest(registry.image("latest"), nil, TLS{}); err == nil {
+		t.Errorf("expected the self-signed certificate of the registry to be rejected")
+	}
+
+	bundle := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: registry.server.Certificate().Raw}))
+	digest, err := resolver.Digest(registry.image("latest"), nil, TLS{CABundle: bundle})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if digest != manifestDigest {
+		t.Errorf("expected %s digest, got %s", manifestDigest, digest)
+	}
+
+	if _, err := NewResolver(time.Hour).Digest(registry.image("latest"), nil, TLS{CABundle: "not a certificate"}); err == nil {
+		t.Errorf("expected an invalid CA bundle to be rejected")
+	}
+}
+
+func TestDigestCache(t *testing.T) {
+	registry := newFakeRegistry("none", true, false)
+	defer registry.server.Close()
+	image := registry.image("latest")
+	resolver := NewResolver(time.Hour)
+	for i := 0; i < 2; i++ {
+		if _, err := resolver.Digest(image, nil, TLS{Insecure: true}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if count := registry.count(http.MethodHead); count != 1 {
+		t.Errorf("expected the digest to be resolved once then cached, got %d requests", count)
+	}
+
+	// digests resolved before the specified time are ignored
+	if _, err := resolver.DigestSince(image, nil, TLS{Insecure: true}, time.Now()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count := registry.count(http.MethodHead); count != 2 {
+		t.Errorf("expected the digest to be resolved again, got %d requests", count)
+	}
+}
+
+func TestDigestCacheIsolation(t *testing.T) {
+	registry := newFakeRegistry("basic", true, false)
+	defer registry.server.Close()
+	image := registry.image("latest")
+	resolver := NewResolver(time.Hour)
+	if _, err := resolver.Digest(image, keychain, TLS{Insecure: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the digest resolved using credentials isn't disclosed to callers lacking them
+	if digest, err := resolver.Digest(image, nil, TLS{Insecure: true}); err == nil {
+		t.Errorf("expected resolution without credentials to fail, got %s", digest)
+	}
+	other := func(registry string) (Credentials, bool) {
+		return Credentials{Username: username, Password: "wrong"}, true
+	}
+	if digest, err := resolver.Digest(image, other, TLS{Insecure: true}); err == nil {
+		t.Errorf("expected resolution with other credentials to fail, got %s", digest)
+	}
+	// nor resolved again without verifying the certificate of the registry as requested
+	if digest, err := resolver.Digest(image, keychain, TLS{}); err == nil {
+		t.Errorf("expected resolution verifying the certificate over plain HTTP to fail, got %s", digest)
+	}
+}
+
+func TestDigestCacheExpiry(t *testing.T) {
+	registry := newFakeRegistry("none", true, false)
+	defer registry.server.Close()
+	image := registry.image("latest")
+	resolver := NewResolver(time.Millisecond)
+	if _, err := resolver.Digest(image, nil, TLS{Insecure: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if _, err := resolver.Digest(image, nil, TLS{Insecure: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count := registry.count(http.MethodHead); count != 2 {
+		t.Errorf("expected the expired digest to be resolved again, got %d requests", count)
+	}
+}
+
+func TestTag(t *testing.T) {
+	for _, auth := range []string{"none", "basic", "bearer", "push"} {
+		t.Run(auth, func(t *testing.T) {
+			registry := newFakeRegistry(auth, true, false)
+			defer registry.server.Close()
+			resolver := NewResolver(time.Hour)
+			digest, err := resolver.Tag(registry.image("latest"), "prod", keychain, TLS{Insecure: true})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if digest != manifestDigest {
+				t.Errorf("expected %s digest, got %s", manifestDigest, digest)
+			}
+			if registry.tags["prod"] != manifest {
+				t.Errorf("expected the manifest to be uploaded as prod, got %v", registry.tags)
+			}
+			if (auth == "bearer" || auth == "push") && (len(registry.scopes) == 0 || registry.scopes[0] != "repository:"+repository+":pull,push") {
+				t.Errorf("expected a push token to be requested, got %v scopes", registry.scopes)
+			}
+
+			// the new tag is cached
+			heads := registry.count(http.MethodHead)
+			if digest, err := resolver.Digest(registry.image("prod"), keychain, TLS{Insecure: true}); err != nil || digest != manifestDigest {
+				t.Errorf("expected %s digest, got %s: %v", manifestDigest, digest, err)
+			}
+			if count := registry.count(http.MethodHead); count != heads {
+				t.Errorf("expected the digest of the new tag to be cached, got %d requests", count-heads)
+			}
+		})
+	}
+}
+
+func TestTagWithoutPushCredentials(t *testing.T) {
+	registry := newFakeRegistry("push", true, false)
+	defer registry.server.Close()
+	if _, err := NewResolver(time.Hour).Tag(registry.image("latest"), "prod", nil, TLS{Insecure: true}); err == nil {
+		t.Errorf("expected tagging without push credentials to fail")
+	}
+	if _, found := registry.tags["prod"]; found {
+		t.Errorf("expected no manifest to be uploaded")
+	}
+}
+
+func TestTagMissingImage(t *testing.T) {
+	registry := newFakeRegistry("none", true, false)
+	defer registry.server.Close()
+	if _, err := NewResolver(time.Hour).Tag(registry.image("missing"), "prod", nil, TLS{Insecure: true}); err == nil {
+		t.Errorf("expected tagging a missing image to fail")
+	}
+	if _, found := registry.tags["prod"]; found {
+		t.Errorf("expected no manifest to be uploaded")
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(`Bearer realm="https://auth.docker.io/token",service="registry.docker.io", scope="repository:library/nginx:pull"`)
+	if scheme != "Bearer" {
+		t.Errorf("expected Bearer scheme, got %s", scheme)
+	}
+	expected := map[string]string{
+		"realm":   "https://auth.docker.io/token",
+		"service": "registry.docker.io",
+		"scope":   "repository:library/nginx:pull",
+	}
+	for key, value := range expected {
+		if params[key] != value {
+			t.Errorf("expected %s %s, got %s", key, value, params[key])
+		}
+	}
+	if scheme, params := parseChallenge("Basic"); scheme != "Basic" || len(params) != 0 {
+		t.Errorf("expected Basic scheme without params, got %s %v", scheme, params)
+	}
+}
@@ -0,0 +1,70 @@
+package tekton
+
+import (
+	"encoding/json"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// The types are only ever exchanged with the API server as JSON, so they're deep copied through their JSON representation
+// rather than using generated code
+func deepCopyJSON(in, out interface{}) {
+	data, err := json.Marshal(in)
+	if err != nil {
+		panic(err)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		panic(err)
+	}
+}
+
+func (in *Task) DeepCopy() *Task {
+	if in == nil {
+		return nil
+	}
+	out := &Task{}
+	deepCopyJSON(in, out)
+	return out
+}
+
+func (in *Task) DeepCopyObject() runtime.Object {
+	return in.DeepCopy()
+}
+
+func (in *TaskList) DeepCopy() *TaskList {
+	if in == nil {
+		return nil
+	}
+	out := &TaskList{}
+	deepCopyJSON(in, out)
+	return out
+}
+
+func (in *TaskList) DeepCopyObject() runtime.Object {
+	return in.DeepCopy()
+}
+
+func (in *TaskRun) DeepCopy() *TaskRun {
+	if in == nil {
+		return nil
+	}
+	out := &TaskRun{}
+	deepCopyJSON(in, out)
+	return out
+}
+
+func (in *TaskRun) DeepCopyObject() runtime.Object {
+	return in.DeepCopy()
+}
+
+func (in *TaskRunList) DeepCopy() *TaskRunList {
+	if in == nil {
+		return nil
+	}
+	out := &TaskRunList{}
+	deepCopyJSON(in, out)
+	return out
+}
+
+func (in *TaskRunList) DeepCopyObject() runtime.Object {
+	return in.DeepCopy()
+}
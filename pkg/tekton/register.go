@@ -0,0 +1,48 @@
+package tekton
+
+import (
+	"fmt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+)
+
+const GroupName = "tekton.dev"
+
+// supportedVersions lists the supported versions of the Tekton API, by order of preference
+var supportedVersions = []string{"v1", "v1beta1"}
+
+// SchemeGroupVersion is the version of the Tekton API used by the operator, as set by Register
+var SchemeGroupVersion = schema.GroupVersion{Group: GroupName, Version: supportedVersions[len(supportedVersions)-1]}
+
+// DetectVersion returns the preferred version of the Tekton API providing both Tasks and TaskRuns on the cluster
+func DetectVersion(config *rest.Config) (string, error) {
+	client, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return "", err
+	}
+	for _, version := range supportedVersions {
+		resources, err := client.ServerResourcesForGroupVersion(GroupName + "/" + version)
+		if err != nil {
+			continue
+		}
+		found := make(map[string]bool, len(resources.APIResources))
+		for _, resource := range resources.APIResources {
+			found[resource.Kind] = true
+		}
+		if found["Task"] && found["TaskRun"] {
+			return version, nil
+		}
+	}
+	return "", fmt.Errorf("no supported version of the Tekton API found, Tekton Pipelines v0.11 or later is required")
+}
+
+// Register registers the Tekton types for the specified version of the API, which is then used by the operator
+func Register(scheme *runtime.Scheme, version string) error {
+	SchemeGroupVersion = schema.GroupVersion{Group: GroupName, Version: version}
+	scheme.AddKnownTypes(SchemeGroupVersion, &Task{}, &TaskList{}, &TaskRun{}, &TaskRunList{})
+	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
+	return nil
+}
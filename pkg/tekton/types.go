@@ -0,0 +1,303 @@
+package tekton
+
+import (
+	"encoding/json"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	duckv1beta1 "knative.dev/pkg/apis/duck/v1beta1"
+)
+
+// The types below model the subset of Tekton's Task and TaskRun used by the operator and are registered for whichever
+// version of the API the cluster provides. The JSON representation of this subset is the same in the v1beta1 and v1
+// versions of the API but for the resources of the steps, which Step serializes according to the registered version.
+
+// ParamType is the type of a parameter value
+type ParamType string
+
+const (
+	ParamTypeString ParamType = "string"
+	ParamTypeArray  ParamType = "array"
+	ParamTypeObject ParamType = "object"
+)
+
+// ParamValue is a string, array or object parameter or result value
+type ParamValue struct {
+	Type      ParamType
+	StringVal string
+	ArrayVal  []string
+	ObjectVal map[string]string
+}
+
+// NewString creates a string ParamValue
+func NewString(value string) ParamValue {
+	return ParamValue{Type: ParamTypeString, StringVal: value}
+}
+
+// NewArray creates an array ParamValue
+func NewArray(values ...string) ParamValue {
+	if values == nil {
+		values = []string{}
+	}
+	return ParamValue{Type: ParamTypeArray, ArrayVal: values}
+}
+
+func (p ParamValue) MarshalJSON() ([]byte, error) {
+	switch p.Type {
+	case ParamTypeArray:
+		return json.Marshal(p.ArrayVal)
+	case ParamTypeObject:
+		return json.Marshal(p.ObjectVal)
+	default:
+		return json.Marshal(p.StringVal)
+	}
+}
+
+func (p *ParamValue) UnmarshalJSON(data []byte) error {
+	if len(data) == 0 || string(data) == "null" {
+		return nil
+	}
+	switch data[0] {
+	case '[':
+		p.Type = ParamTypeArray
+		return json.Unmarshal(data, &p.ArrayVal)
+	case '{':
+		p.Type = ParamTypeObject
+		return json.Unmarshal(data, &p.ObjectVal)
+	case '"':
+		p.Type = ParamTypeString
+		return json.Unmarshal(data, &p.StringVal)
+	default:
+		return fmt.Errorf("invalid parameter value: %s", data)
+	}
+}
+
+// Param binds a value to a Task parameter
+type Param struct {
+	Name  string     `json:"name"`
+	Value ParamValue `json:"value"`
+}
+
+// ParamSpec declares a Task parameter
+type ParamSpec struct {
+	Name        string      `json:"name"`
+	Type        ParamType   `json:"type,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Default     *ParamValue `json:"default,omitempty"`
+}
+
+// WorkspaceDeclaration declares a volume the Task expects to be provided by its TaskRuns
+type WorkspaceDeclaration struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MountPath   string `json:"mountPath,omitempty"`
+	ReadOnly    bool   `json:"readOnly,omitempty"`
+	Optional    bool   `json:"optional,omitempty"`
+}
+
+// TaskResult declares a value the Task's steps write to $(results.<name>.path)
+type TaskResult struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// Step is a container run by a Task, optionally running the specified script
+type Step struct {
+	corev1.Container `json:",inline"`
+	Script           string `json:"script,omitempty"`
+}
+
+// step has the fields of Step without its JSON methods
+type step Step
+
+func (s Step) MarshalJSON() ([]byte, error) {
+	return marshalStep(s, SchemeGroupVersion.Version)
+}
+
+func (s *Step) UnmarshalJSON(data []byte) error {
+	return unmarshalStep(data, s, SchemeGroupVersion.Version)
+}
+
+// marshalStep serializes the specified step for the specified version of the API: the v1 API names computeResources
+// the resources field of the container
+func marshalStep(s Step, version string) ([]byte, error) {
+	data, err := json.Marshal(step(s))
+	if err != nil || version != "v1" {
+		return data, err
+	}
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	resources := fields["resources"]
+	delete(fields, "resources")
+	if len(resources) > 0 && string(resources) != "{}" {
+		fields["computeResources"] = resources
+	}
+	return json.Marshal(fields)
+}
+
+// unmarshalStep deserializes the specified step as serialized by the specified version of the API
+func unmarshalStep(data []byte, s *Step, version string) error {
+	if err := json.Unmarshal(data, (*step)(s)); err != nil || version != "v1" {
+		return err
+	}
+	resources := struct {
+		ComputeResources corev1.ResourceRequirements `json:"computeResources,omitempty"`
+	}{}
+	if err := json.Unmarshal(data, &resources); err != nil {
+		return err
+	}
+	s.Resources = resources.ComputeResources
+	return nil
+}
+
+// TaskSpec describes the steps of a Task along with its parameters, workspaces and results
+type TaskSpec struct {
+	Description string                 `json:"description,omitempty"`
+	Params      []ParamSpec            `json:"params,omitempty"`
+	Workspaces  []WorkspaceDeclaration `json:"workspaces,omitempty"`
+	Results     []TaskResult           `json:"results,omitempty"`
+	Steps       []Step                 `json:"steps,omitempty"`
+	Volumes     []corev1.Volume        `json:"volumes,omitempty"`
+}
+
+// Task is a reusable sequence of steps
+type Task struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              TaskSpec `json:"spec"`
+}
+
+// TaskList is a list of Tasks
+type TaskList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Task `json:"items"`
+}
+
+// TaskRef references a Task by name
+type TaskRef struct {
+	Name string `json:"name"`
+}
+
+// WorkspaceBinding provides the volume backing a workspace declared by a Task
+type WorkspaceBinding struct {
+	Name                  string                                    `json:"name"`
+	SubPath               string                                    `json:"subPath,omitempty"`
+	EmptyDir              *corev1.EmptyDirVolumeSource              `json:"emptyDir,omitempty"`
+	PersistentVolumeClaim *corev1.PersistentVolumeClaimVolumeSource `json:"persistentVolumeClaim,omitempty"`
+	ConfigMap             *corev1.ConfigMapVolumeSource             `json:"configMap,omitempty"`
+	Secret                *corev1.SecretVolumeSource                `json:"secret,omitempty"`
+}
+
+// PodTemplate customizes the pod running a TaskRun
+type PodTemplate struct {
+	NodeSelector              map[string]string             `json:"nodeSelector,omitempty"`
+	Tolerations               []corev1.Toleration           `json:"tolerations,omitempty"`
+	Affinity                  *corev1.Affinity              `json:"affinity,omitempty"`
+	TopologySpreadConstraints []TopologySpreadConstraint    `json:"topologySpreadConstraints,omitempty"`
+	SecurityContext           *PodSecurityContext           `json:"securityContext,omitempty"`
+	ImagePullSecrets          []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+}
+
+// TopologySpreadConstraint spreads pods across a topology domain such as zones or nodes. It isn't part of the Kubernetes
+// API the operator is built against.
+type TopologySpreadConstraint struct {
+	MaxSkew           int32                 `json:"maxSkew"`
+	TopologyKey       string                `json:"topologyKey"`
+	WhenUnsatisfiable string                `json:"whenUnsatisfiable"`
+	LabelSelector     *metav1.LabelSelector `json:"labelSelector,omitempty"`
+}
+
+// PodSecurityContext adds the seccomp profile, which isn't part of the Kubernetes API the operator is built against, to
+// the security context of the pod running a TaskRun
+type PodSecurityContext struct {
+	corev1.PodSecurityContext `json:",inline"`
+	SeccompProfile            *SeccompProfile `json:"seccompProfile,omitempty"`
+}
+
+// SeccompProfile selects the seccomp profile of a pod, e.g. RuntimeDefault
+type SeccompProfile struct {
+	Type string `json:"type"`
+}
+
+const (
+	// TaskRunSpecStatusCancelled is the spec status requesting a TaskRun to be cancelled
+	TaskRunSpecStatusCancelled = "TaskRunCancelled"
+	// TaskRunReasonCancelled and TaskRunReasonTimedOut are the reasons of the Succeeded condition of the TaskRuns which
+	// were cancelled or didn't complete in time
+	TaskRunReasonCancelled = "TaskRunCancelled"
+	TaskRunReasonTimedOut  = "TaskRunTimeout"
+	// TaskRunReasonFailed is the generic reason of the Succeeded condition of the TaskRuns which failed, e.g. because of a
+	// step exiting with an error
+	TaskRunReasonFailed = "Failed"
+	// TaskRunReasonImagePullFailed, TaskRunReasonPodCreationFailed and TaskRunReasonExceededNodeResources are the reasons
+	// of the Succeeded condition of the TaskRuns whose pod couldn't pull the image of a step, be created or be scheduled
+	TaskRunReasonImagePullFailed       = "TaskRunImagePullFailed"
+	TaskRunReasonPodCreationFailed     = "PodCreationFailed"
+	TaskRunReasonExceededNodeResources = "ExceededNodeResources"
+)
+
+// TaskRunSpec describes how to run a Task
+type TaskRunSpec struct {
+	TaskRef            *TaskRef           `json:"taskRef,omitempty"`
+	Params             []Param            `json:"params,omitempty"`
+	ServiceAccountName string             `json:"serviceAccountName,omitempty"`
+	Workspaces         []WorkspaceBinding `json:"workspaces,omitempty"`
+	PodTemplate        *PodTemplate       `json:"podTemplate,omitempty"`
+	Timeout            *metav1.Duration   `json:"timeout,omitempty"`
+	Status             string             `json:"status,omitempty"`
+}
+
+// StepState is the state of the container running a step
+type StepState struct {
+	corev1.ContainerState `json:",inline"`
+	Name                  string `json:"name,omitempty"`
+	ContainerName         string `json:"container,omitempty"`
+	ImageID               string `json:"imageID,omitempty"`
+}
+
+// TaskRunResult is a result written by the steps of a TaskRun
+type TaskRunResult struct {
+	Name  string     `json:"name"`
+	Value ParamValue `json:"value"`
+}
+
+// TaskRunStatus is the observed state of a TaskRun, the outcome being reported by its Succeeded condition
+type TaskRunStatus struct {
+	duckv1beta1.Status `json:",inline"`
+	PodName            string       `json:"podName,omitempty"`
+	StartTime          *metav1.Time `json:"startTime,omitempty"`
+	CompletionTime     *metav1.Time `json:"completionTime,omitempty"`
+	Steps              []StepState  `json:"steps,omitempty"`
+	// TaskResults holds the results in the v1beta1 API
+	TaskResults []TaskRunResult `json:"taskResults,omitempty"`
+	// Results holds the results in the v1 API
+	Results []TaskRunResult `json:"results,omitempty"`
+}
+
+// Result returns the value of the specified result, whichever version of the API reported it
+func (s TaskRunStatus) Result(name string) (string, bool) {
+	for _, result := range append(append([]TaskRunResult{}, s.Results...), s.TaskResults...) {
+		if result.Name == name {
+			return result.Value.StringVal, true
+		}
+	}
+	return "", false
+}
+
+// TaskRun runs a Task
+type TaskRun struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              TaskRunSpec   `json:"spec"`
+	Status            TaskRunStatus `json:"status,omitempty"`
+}
+
+// TaskRunList is a list of TaskRuns
+type TaskRunList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []TaskRun `json:"items"`
+}
@@ -0,0 +1,49 @@
+package tekton
+
+import (
+	"encoding/json"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"reflect"
+	"testing"
+)
+
+func TestStepJSON(t *testing.T) {
+	limited := Step{Container: corev1.Container{Name: "build", Resources: corev1.ResourceRequirements{
+		Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+	}}, Script: "make"}
+	tests := []struct {
+		name     string
+		version  string
+		step     Step
+		expected map[string]bool
+	}{
+		{name: "v1beta1", version: "v1beta1", step: limited, expected: map[string]bool{"resources": true}},
+		{name: "v1", version: "v1", step: limited, expected: map[string]bool{"computeResources": true}},
+		{name: "v1 without resources", version: "v1", step: Step{Container: corev1.Container{Name: "build"}}, expected: map[string]bool{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := marshalStep(test.step, test.version)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			fields := make(map[string]json.RawMessage)
+			if err := json.Unmarshal(data, &fields); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, field := range []string{"resources", "computeResources"} {
+				if _, found := fields[field]; found != test.expected[field] {
+					t.Errorf("expected %s field to be serialized: %t, got %s", field, test.expected[field], data)
+				}
+			}
+			step := Step{}
+			if err := unmarshalStep(data, &step, test.version); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(step, test.step) {
+				t.Errorf("expected %+v once deserialized, got %+v", test.step, step)
+			}
+		})
+	}
+}
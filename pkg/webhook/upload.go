@@ -0,0 +1,196 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	halkyon "halkyon.io/api/component/v1beta1"
+	"halkyon.io/operator/pkg/controller/component"
+	"io"
+	"io/ioutil"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"net/http"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+)
+
+const (
+	// UploadPath prefixes the path on which source archives are uploaded: /upload/<namespace>/<component>
+	UploadPath = "/upload/"
+	// maxArchiveSize keeps the ConfigMaps storing the uploaded archives below the 1MiB size limit of Kubernetes objects:
+	// larger archives must be stored in a PVC referenced by the SourceArchiveAnnotation
+	maxArchiveSize = 1000 * 1024
+)
+
+// UploadHandler stores the tar, tar.gz or zip source archives uploaded for build mode components in ConfigMaps owned by
+// them, then builds them from the uploaded source. The ConfigMaps are deleted by the operator once no build kept in the
+// history of the component built them. Uploads must be authenticated by the bearer token of a user allowed
+// to update the component, which is why they're only accepted over TLS.
+type UploadHandler struct {
+	client client.Client
+}
+
+func (h *UploadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodPost {
+		reply(w, http.StatusMethodNotAllowed, response{Message: "only PUT and POST requests are accepted"})
+		return
+	}
+	if r.TLS == nil {
+		reply(w, http.StatusForbidden, response{Message: "source archives must be uploaded over HTTPS: configure the TLS certificate of the webhook server"})
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, UploadPath), "/")
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		reply(w, http.StatusNotFound, response{Message: "source archives must be uploaded to " + UploadPath + "<namespace>/<component>"})
+		return
+	}
+	name := types.NamespacedName{Namespace: parts[0], Name: parts[1]}
+	if status, err := h.authorize(r, name); err != nil {
+		reply(w, status, response{Message: err.Error()})
+		return
+	}
+
+	tooLarge := response{Message: fmt.Sprintf("source archives cannot exceed %d bytes: store larger ones in a PVC referenced by the '%s' annotation", maxArchiveSize, component.SourceArchiveAnnotation)}
+	if r.ContentLength > maxArchiveSize {
+		reply(w, http.StatusRequestEntityTooLarge, tooLarge)
+		return
+	}
+	archive, err := ioutil.ReadAll(io.LimitReader(r.Body, maxArchiveSize+1))
+	if err != nil {
+		reply(w, http.StatusBadRequest, response{Message: err.Error()})
+		return
+	}
+	if len(archive) > maxArchiveSize {
+		reply(w, http.StatusRequestEntityTooLarge, tooLarge)
+		return
+	}
+	if !isArchive(archive) {
+		reply(w, http.StatusUnsupportedMediaType, response{Message: "source archives must be tar, tar.gz or zip archives"})
+		return
+	}
+
+	c := &halkyon.Component{}
+	if err := h.client.Get(context.TODO(), name, c); err != nil {
+		if errors.IsNotFound(err) {
+			reply(w, http.StatusNotFound, response{Message: fmt.Sprintf("component '%s' doesn't exist", name)})
+			return
+		}
+		reply(w, http.StatusInternalServerError, response{Message: err.Error()})
+		return
+	}
+	if c.Spec.DeploymentMode != halkyon.BuildDeploymentMode {
+		reply(w, http.StatusConflict, response{Message: fmt.Sprintf("component '%s' isn't built: its deployment mode must be %s", name, halkyon.BuildDeploymentMode)})
+		return
+	}
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(archive))
+	configMap, err := h.store(c, archive, digest)
+	if err != nil {
+		reply(w, http.StatusInternalServerError, response{Message: err.Error()})
+		return
+	}
+	if err := h.build(name, configMap); err != nil {
+		reply(w, http.StatusInternalServerError, response{Message: err.Error()})
+		return
+	}
+	log.Info(fmt.Sprintf("source archive %s uploaded", digest), "component", c.Name, "namespace", c.Namespace)
+	reply(w, http.StatusAccepted, response{Message: "build triggered", Triggered: []string{name.String()}, Digest: digest})
+}
+
+// authorize checks that the request bears the token of a user allowed to update the specified component, returning the
+// HTTP status to reply with otherwise
+func (h *UploadHandler) authorize(r *http.Request, name types.NamespacedName) (int, error) {
+	header := r.Header.Get("Authorization")
+	token := strings.TrimPrefix(header, "Bearer ")
+	if len(token) == 0 || token == header {
+		return http.StatusUnauthorized, fmt.Errorf("uploads must be authenticated using a bearer token")
+	}
+	review := &authenticationv1.TokenReview{Spec: authenticationv1.TokenReviewSpec{Token: token}}
+	if err := h.client.Create(context.TODO(), review); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !review.Status.Authenticated {
+		return http.StatusUnauthorized, fmt.Errorf("invalid bearer token")
+	}
+
+	user := review.Status.User
+	extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+	for key, values := range user.Extra {
+		extra[key] = authorizationv1.ExtraValue(values)
+	}
+	access := &authorizationv1.SubjectAccessReview{Spec: authorizationv1.SubjectAccessReviewSpec{
+		User:   user.Username,
+		UID:    user.UID,
+		Groups: user.Groups,
+		Extra:  extra,
+		ResourceAttributes: &authorizationv1.ResourceAttributes{
+			Namespace: name.Namespace,
+			Verb:      "update",
+			Group:     halkyon.SchemeGroupVersion.Group,
+			Resource:  "components",
+			Name:      name.Name,
+		},
+	}}
+	if err := h.client.Create(context.TODO(), access); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !access.Status.Allowed {
+		return http.StatusForbidden, fmt.Errorf("'%s' isn't allowed to update component '%s'", user.Username, name)
+	}
+	return 0, nil
+}
+
+// store stores the specified source archive in a ConfigMap owned by the component, returning its name
+func (h *UploadHandler) store(c *halkyon.Component, archive []byte, digest string) (string, error) {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            component.SourceUploadName(c, digest),
+			Namespace:       c.Namespace,
+			Labels:          map[string]string{component.SourceUploadLabelKey: c.Name},
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(c, halkyon.SchemeGroupVersion.WithKind("Component"))},
+		},
+		BinaryData: map[string][]byte{component.UploadedSourceKey: archive},
+	}
+	if err := h.client.Create(context.TODO(), configMap); err != nil && !errors.IsAlreadyExists(err) {
+		return "", err
+	}
+	return configMap.Name, nil
+}
+
+// build points the specified component to the uploaded source archive held by the specified ConfigMap, which starts a
+// new build, retrying if the component is concurrently modified
+func (h *UploadHandler) build(name types.NamespacedName, configMap string) error {
+	value, err := json.Marshal(component.SourceArchive{ConfigMap: configMap, Path: component.UploadedSourceKey})
+	if err != nil {
+		return err
+	}
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		c := &halkyon.Component{}
+		if err := h.client.Get(context.TODO(), name, c); err != nil {
+			return err
+		}
+		if c.Annotations[component.SourceArchiveAnnotation] == string(value) {
+			// this archive was already uploaded
+			return nil
+		}
+		if c.Annotations == nil {
+			c.Annotations = make(map[string]string, 1)
+		}
+		c.Annotations[component.SourceArchiveAnnotation] = string(value)
+		return h.client.Update(context.TODO(), c)
+	})
+}
+
+// isArchive returns whether the specified data looks like a zip, gzip or tar archive, judging by its first bytes
+func isArchive(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("PK\x03\x04")) ||
+		bytes.HasPrefix(data, []byte{0x1f, 0x8b}) ||
+		(len(data) > 262 && bytes.Equal(data[257:262], []byte("ustar")))
+}
@@ -0,0 +1,214 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	halkyon "halkyon.io/api/component/v1beta1"
+	"halkyon.io/operator/pkg/controller/component"
+	"halkyon.io/operator/pkg/git"
+	"io"
+	"io/ioutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+	"strings"
+	"time"
+)
+
+const (
+	// AddressEnvVar holds the name of the env variable containing the address the webhook server listens on
+	AddressEnvVar = "WEBHOOK_ADDRESS"
+	// TLSDirEnvVar holds the name of the env variable containing the directory holding the tls.crt certificate and
+	// tls.key private key the webhook server is served with, /etc/halkyon/webhook-tls by default
+	TLSDirEnvVar = "WEBHOOK_TLS_DIR"
+	// SecretEnvVar holds the name of the env variable containing the secret shared with git hosts, used for components
+	// which don't specify their own using the WebhookSecretAnnotation
+	SecretEnvVar = "WEBHOOK_SECRET"
+	// SecretKey is the key of the webhook secret in the Secrets referenced by the WebhookSecretAnnotation
+	SecretKey = "secret"
+	// Path is the path on which push webhooks are accepted
+	Path = "/webhook"
+
+	defaultAddress = ":8090"
+	defaultTLSDir  = "/etc/halkyon/webhook-tls"
+	maxPayloadSize = 5 * 1024 * 1024
+)
+
+var log = logf.Log.WithName("webhook")
+
+// Server serves the push webhook and source archive upload endpoints. It's meant to be added to the operator's manager which starts and stops it.
+type Server struct {
+	address string
+	tlsDir  string
+	handler *Handler
+	uploads *UploadHandler
+}
+
+// NewServer creates a Server listening on the address specified by the AddressEnvVar env variable, :8090 by default,
+// served over TLS if a certificate is found in the directory specified by the TLSDirEnvVar env variable
+func NewServer(c client.Client) *Server {
+	address, found := os.LookupEnv(AddressEnvVar)
+	if !found {
+		address = defaultAddress
+	}
+	tlsDir, found := os.LookupEnv(TLSDirEnvVar)
+	if !found {
+		tlsDir = defaultTLSDir
+	}
+	return &Server{address: address, tlsDir: tlsDir, handler: &Handler{client: c}, uploads: &UploadHandler{client: c}}
+}
+
+// Start serves requests until the stop channel is closed
+func (s *Server) Start(stop <-chan struct{}) error {
+	mux := http.NewServeMux()
+	mux.Handle(Path, s.handler)
+	mux.Handle(UploadPath, s.uploads)
+	server := &http.Server{Addr: s.address, Handler: mux}
+	go func() {
+		<-stop
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(ctx)
+	}()
+	cert, key := filepath.Join(s.tlsDir, "tls.crt"), filepath.Join(s.tlsDir, "tls.key")
+	var err error
+	if exists(cert) && exists(key) {
+		log.Info("listening over TLS for push webhooks on " + s.address + Path + " and source archive uploads on " + s.address + UploadPath)
+		err = server.ListenAndServeTLS(cert, key)
+	} else {
+		// uploads are authenticated by a Kubernetes token which mustn't be sent in clear text
+		log.Info("no TLS certificate found in " + s.tlsDir + ": listening for push webhooks on " + s.address + Path + ", source archive uploads are refused")
+		err = server.ListenAndServe()
+	}
+	if err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+func exists(file string) bool {
+	_, err := os.Stat(file)
+	return err == nil
+}
+
+// Handler triggers a new build of the build mode components whose git repository and ref match the pushes notified by
+// GitHub, GitLab, Gitea or compatible webhooks. Payloads are only acted upon for components whose webhook secret they
+// were signed with.
+type Handler struct {
+	client client.Client
+}
+
+type response struct {
+	Message   string   `json:"message"`
+	Triggered []string `json:"triggered,omitempty"`
+	Digest    string   `json:"digest,omitempty"`
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		reply(w, http.StatusMethodNotAllowed, response{Message: "only POST requests are accepted"})
+		return
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxPayloadSize))
+	if err != nil {
+		reply(w, http.StatusBadRequest, response{Message: err.Error()})
+		return
+	}
+	event, err := git.ParsePushEvent(r.Header, body)
+	if err != nil {
+		reply(w, http.StatusBadRequest, response{Message: err.Error()})
+		return
+	}
+	if event == nil {
+		reply(w, http.StatusOK, response{Message: "ignored: not a push event"})
+		return
+	}
+
+	components := &halkyon.ComponentList{}
+	if err := h.client.List(context.TODO(), &client.ListOptions{}, components); err != nil {
+		reply(w, http.StatusInternalServerError, response{Message: err.Error()})
+		return
+	}
+	triggered := make([]string, 0, len(components.Items))
+	var failures []string
+	for _, c := range components.Items {
+		if c.Spec.DeploymentMode != halkyon.BuildDeploymentMode || !event.Matches(c.Spec.BuildConfig.URL, component.BuildRef(&c)) {
+			continue
+		}
+		secret, err := h.secretFor(&c)
+		if err != nil {
+			log.Error(err, "couldn't retrieve webhook secret", "component", c.Name, "namespace", c.Namespace)
+			continue
+		}
+		if !git.VerifySignature(r.Header, body, secret) {
+			continue
+		}
+		// a failure to trigger the build of a component doesn't prevent the other components from being built
+		if err := h.trigger(types.NamespacedName{Name: c.Name, Namespace: c.Namespace}, event); err != nil {
+			log.Error(err, "couldn't trigger build", "component", c.Name, "namespace", c.Namespace)
+			failures = append(failures, fmt.Sprintf("%s/%s: %s", c.Namespace, c.Name, err.Error()))
+			continue
+		}
+		triggered = append(triggered, c.Namespace+"/"+c.Name)
+	}
+
+	if len(failures) > 0 {
+		message := "couldn't trigger builds of " + strings.Join(failures, "; ")
+		reply(w, http.StatusInternalServerError, response{Message: message, Triggered: triggered})
+		return
+	}
+
+	if len(triggered) == 0 {
+		// whether components build the pushed repository isn't disclosed to callers which don't know their secret
+		reply(w, http.StatusOK, response{Message: "ignored: no build triggered"})
+		return
+	}
+	log.Info(fmt.Sprintf("%s push to %s triggered builds of %v", event.Provider, event.Ref, triggered), "commit", event.Commit)
+	reply(w, http.StatusAccepted, response{Message: "build triggered", Triggered: triggered})
+}
+
+// secretFor returns the webhook secret of the specified component: the one held by the Secret referenced by its
+// WebhookSecretAnnotation if any, the operator-wide one otherwise
+func (h *Handler) secretFor(c *halkyon.Component) (string, error) {
+	name, found := c.Annotations[component.WebhookSecretAnnotation]
+	if !found || len(name) == 0 {
+		return os.Getenv(SecretEnvVar), nil
+	}
+	secret := &corev1.Secret{}
+	if err := h.client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: c.Namespace}, secret); err != nil {
+		return "", err
+	}
+	return string(secret.Data[SecretKey]), nil
+}
+
+// trigger requests a new build of the commit pushed to the specified component's ref, retrying if it's concurrently
+// modified
+func (h *Handler) trigger(name types.NamespacedName, event *git.PushEvent) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		c := &halkyon.Component{}
+		if err := h.client.Get(context.TODO(), name, c); err != nil {
+			return err
+		}
+		if !event.Matches(c.Spec.BuildConfig.URL, component.BuildRef(c)) {
+			// the component's repository or ref changed since it was matched
+			return nil
+		}
+		if !component.TriggerBuild(c, event.Commit) {
+			// this commit was already built, e.g. the webhook got delivered again
+			return nil
+		}
+		return h.client.Update(context.TODO(), c)
+	})
+}
+
+func reply(w http.ResponseWriter, status int, body response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}